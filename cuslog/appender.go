@@ -0,0 +1,91 @@
+package cuslog
+
+import (
+	"io"
+	"os"
+	"reflect"
+)
+
+// Appender 独立的输出目标，拥有自己的最低级别、formatter和output
+type Appender struct {
+	Level     Level
+	Formatter Formatter
+	Output    io.Writer
+}
+
+func NewAppender(output io.Writer, level Level, formatter Formatter) *Appender {
+	return &Appender{Level: level, Formatter: formatter, Output: output}
+}
+
+// WithAppenders 使用appenders替代output和formatter，每条entry写入所有级别满足的appender
+func WithAppenders(appenders ...*Appender) Option {
+	return Option(func(options2 *options) {
+		options2.appenders = appenders
+		options2.formatters = options2.formatters[:0:0]
+		options2.appenderSlots = make([]int, len(appenders))
+		for i, a := range appenders {
+			if a.Output == nil {
+				a.Output = os.Stderr
+			}
+			if a.Formatter == nil {
+				a.Formatter = &TextFormatter{}
+			}
+			options2.appenderSlots[i] = formatterSlot(&options2.formatters, a.Formatter)
+		}
+	})
+}
+
+// formatterSlot 相同的formatter共用一个位置，每条entry只格式化一次
+func formatterSlot(formatters *[]Formatter, f Formatter) int {
+	if reflect.TypeOf(f).Comparable() {
+		for i, exist := range *formatters {
+			if reflect.TypeOf(exist) == reflect.TypeOf(f) && exist == f {
+				return i
+			}
+		}
+	}
+	*formatters = append(*formatters, f)
+	return len(*formatters) - 1
+}
+
+// formatAppenders 按formatter格式化到各自的buffer，e.Buffer指向第一个结果
+func (e *Entry) formatAppenders() {
+	opt := e.opt
+	for len(e.buffers) < len(opt.formatters) {
+		e.buffers = append(e.buffers, make([]byte, 0, 512))
+	}
+	e.formatted = e.formatted[:0]
+	for range opt.formatters {
+		e.formatted = append(e.formatted, false)
+	}
+	first := -1
+	for i, a := range opt.appenders {
+		if !e.flight && !e.Level.allowed(a.Level) {
+			continue
+		}
+		slot := opt.appenderSlots[i]
+		if !e.formatted[slot] {
+			buf, err := opt.formatters[slot].Format(e.buffers[slot][:0], e)
+			e.buffers[slot], e.formatted[slot] = buf, true
+			if err != nil {
+				e.handleError(err, len(buf) == 0)
+			}
+		}
+		if first < 0 {
+			first = slot
+		}
+	}
+	if first >= 0 {
+		e.Buffer = e.buffers[first]
+	}
+}
+
+func (e *Entry) writeAppenders() {
+	opt := e.opt
+	for i, a := range opt.appenders {
+		if !e.flight && !e.Level.allowed(a.Level) {
+			continue
+		}
+		e.writeTo(a.Output, opt.appenderLocks[i], e.buffers[opt.appenderSlots[i]])
+	}
+}
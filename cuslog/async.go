@@ -0,0 +1,197 @@
+package cuslog
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncPolicy 异步队列满时的处理策略
+type AsyncPolicy uint8
+
+const (
+	// AsyncBlock 阻塞调用方直到队列有空位
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropNewest 丢弃当前entry
+	AsyncDropNewest
+	// AsyncDropOldest 丢弃队列中最早的entry
+	AsyncDropOldest
+)
+
+type asyncMessage struct {
+	//入队时的配置，写入失败时使用其中的ErrorHandler和备用输出
+	opt    *options
+	output io.Writer
+	//output对应的锁，与同步写入共用
+	mu   *sync.Mutex
+	data []byte
+	//非nil表示flush标记，worker处理到此处时关闭
+	flushed chan struct{}
+}
+
+// asyncWriter 后台goroutine按顺序写出已格式化的数据
+type asyncWriter struct {
+	queue   chan *asyncMessage
+	policy  AsyncPolicy
+	dropped uint64
+	done    chan struct{}
+
+	//读锁保护入队，写锁保护关闭队列
+	mu     sync.RWMutex
+	closed bool
+}
+
+var asyncMessagePool = sync.Pool{New: func() interface{} { return &asyncMessage{} }}
+
+func newAsyncWriter(size int, policy AsyncPolicy) *asyncWriter {
+	if size <= 0 {
+		size = 1
+	}
+	a := &asyncWriter{
+		queue:  make(chan *asyncMessage, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	for msg := range a.queue {
+		if msg.flushed != nil {
+			close(msg.flushed)
+			continue
+		}
+		msg.mu.Lock()
+		n, err := msg.output.Write(msg.data)
+		msg.mu.Unlock()
+		if err == nil && n < len(msg.data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			msg.opt.handleAsyncError(err, msg.data)
+		}
+		msg.opt, msg.output, msg.mu, msg.data = nil, nil, nil, msg.data[:0]
+		asyncMessagePool.Put(msg)
+	}
+}
+
+// write 复制data入队，noDrop为true时忽略丢弃策略；已关闭时返回false，由调用方同步写入
+func (a *asyncWriter) write(opt *options, output io.Writer, mu *sync.Mutex, data []byte, noDrop bool) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return false
+	}
+	msg := asyncMessagePool.Get().(*asyncMessage)
+	msg.opt, msg.output, msg.mu, msg.data = opt, output, mu, append(msg.data, data...)
+
+	if a.policy == AsyncBlock || noDrop {
+		a.queue <- msg
+		return true
+	}
+	for {
+		select {
+		case a.queue <- msg:
+			return true
+		default:
+		}
+		if a.policy == AsyncDropNewest {
+			atomic.AddUint64(&a.dropped, 1)
+			msg.opt, msg.output, msg.mu, msg.data = nil, nil, nil, msg.data[:0]
+			asyncMessagePool.Put(msg)
+			return true
+		}
+		//AsyncDropOldest：取出一条丢弃后重试，flush标记不能丢弃
+		select {
+		case old := <-a.queue:
+			if old.flushed != nil {
+				close(old.flushed)
+			} else {
+				atomic.AddUint64(&a.dropped, 1)
+			}
+		default:
+		}
+	}
+}
+
+// flush 等待当前已入队的数据全部写出
+func (a *asyncWriter) flush() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	flushed := make(chan struct{})
+	a.queue <- &asyncMessage{flushed: flushed}
+	<-flushed
+}
+
+func (a *asyncWriter) close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+func (a *asyncWriter) droppedCount() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+type syncer interface {
+	Sync() error
+}
+
+// Flush 写出异步队列中的数据，并同步实现了Sync方法的output
+func (l *logger) Flush() {
+	opt := l.opt.Load()
+	if opt.dedup != nil {
+		opt.dedup.flush(l)
+	}
+	if opt.async != nil {
+		opt.async.flush()
+	}
+	if len(opt.appenders) == 0 {
+		syncOutput(opt.output, opt.outputMu)
+	}
+	for i, appender := range opt.appenders {
+		syncOutput(appender.Output, opt.appenderLocks[i])
+	}
+}
+
+func syncOutput(output io.Writer, mu *sync.Mutex) {
+	if s, ok := output.(syncer); ok {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = s.Sync()
+	}
+}
+
+// Close 写出剩余数据并停止异步写入，之后的日志同步写出
+func (l *logger) Close() {
+	l.Flush()
+	if a := l.opt.Load().async; a != nil {
+		a.close()
+	}
+}
+
+// Dropped 返回异步队列满时丢弃的entry数量
+func (l *logger) Dropped() uint64 {
+	a := l.opt.Load().async
+	if a == nil {
+		return 0
+	}
+	return a.droppedCount()
+}
+
+func Flush() {
+	std.Flush()
+}
+
+func Close() {
+	std.Close()
+}
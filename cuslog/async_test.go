@@ -0,0 +1,138 @@
+package cuslog
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gateWriter 第一次写入时阻塞，直到release，用于让异步队列处于已满状态
+type gateWriter struct {
+	lockedBuffer
+	started chan struct{}
+	gate    chan struct{}
+	first   bool
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{started: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	if !w.first {
+		w.first = true
+		close(w.started)
+		<-w.gate
+	}
+	return w.lockedBuffer.Write(p)
+}
+
+func (w *gateWriter) release() {
+	close(w.gate)
+}
+
+// fillQueue 后台goroutine阻塞在第一条的写入上，再写入queueSize条填满队列
+func fillQueue(l *logger, w *gateWriter, queueSize int) {
+	l.Info("m0")
+	<-w.started
+	for i := 1; i <= queueSize; i++ {
+		l.Info("m" + strconv.Itoa(i))
+	}
+}
+
+func messages(w *gateWriter) string {
+	var msgs []string
+	for _, line := range w.Lines() {
+		msgs = append(msgs, line[strings.LastIndexByte(line, ' ')+1:])
+	}
+	return strings.Join(msgs, ",")
+}
+
+func TestAsyncDropNewest(t *testing.T) {
+	w := newGateWriter()
+	l := New(WithOutput(w), WithDisableCaller(true), WithAsync(2, AsyncDropNewest))
+	fillQueue(l, w, 2)
+	l.Info("m3")
+	if n := l.Dropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+	w.release()
+	l.Close()
+	if got := messages(w); got != "m0,m1,m2" {
+		t.Errorf("got %s, want m0,m1,m2", got)
+	}
+}
+
+func TestAsyncDropOldest(t *testing.T) {
+	w := newGateWriter()
+	l := New(WithOutput(w), WithDisableCaller(true), WithAsync(2, AsyncDropOldest))
+	fillQueue(l, w, 2)
+	l.Info("m3")
+	if n := l.Dropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+	w.release()
+	l.Close()
+	if got := messages(w); got != "m0,m2,m3" {
+		t.Errorf("got %s, want m0,m2,m3", got)
+	}
+}
+
+func TestAsyncBlock(t *testing.T) {
+	w := newGateWriter()
+	l := New(WithOutput(w), WithDisableCaller(true), WithAsync(2, AsyncBlock))
+	fillQueue(l, w, 2)
+	done := make(chan struct{})
+	go func() {
+		l.Info("m3")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("write should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.release()
+	<-done
+	l.Close()
+	if got := messages(w); got != "m0,m1,m2,m3" {
+		t.Errorf("got %s, want m0,m1,m2,m3", got)
+	}
+	if n := l.Dropped(); n != 0 {
+		t.Errorf("dropped = %d, want 0", n)
+	}
+}
+
+func TestAsyncBypassLevel(t *testing.T) {
+	w := newGateWriter()
+	l := New(WithOutput(w), WithDisableCaller(true), WithAsync(2, AsyncDropNewest), WithAsyncBypassLevel(ErrorLevel))
+	fillQueue(l, w, 2)
+	l.Info("dropped")
+	done := make(chan struct{})
+	go func() {
+		l.Error("kept")
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	w.release()
+	<-done
+	l.Close()
+	if got := messages(w); got != "m0,m1,m2,kept" {
+		t.Errorf("got %s, want m0,m1,m2,kept", got)
+	}
+	if n := l.Dropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+}
+
+func TestAsyncCloseWritesSynchronously(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithAsync(4, AsyncBlock))
+	l.Info("queued")
+	l.Close()
+	l.Info("after close")
+	if got := len(buf.Lines()); got != 2 {
+		t.Errorf("got %d lines, want 2: %q", got, buf.String())
+	}
+}
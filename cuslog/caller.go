@@ -0,0 +1,122 @@
+package cuslog
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// CallerPath 控制Entry.File的格式
+type CallerPath uint8
+
+const (
+	// CallerPathFull 编译时的完整路径，text、logfmt等格式只输出文件名
+	CallerPathFull CallerPath = iota
+	// CallerPathModule 相对于主模块的路径，如internal/db/query.go，其他模块的文件输出包路径，如github.com/x/y/z.go
+	CallerPathModule
+	// CallerPathShort 包目录和文件名，如db/query.go
+	CallerPathShort
+)
+
+// callerInfo 同一调用位置的信息只解析一次
+type callerInfo struct {
+	file     string
+	module   string
+	short    string
+	line     int
+	fn       string
+	pkg      string
+	function string
+}
+
+var callerCache sync.Map
+
+var (
+	buildInfoOnce sync.Once
+	mainModule    string
+	mainPackage   string
+)
+
+func callerForPC(pc uintptr) *callerInfo {
+	if v, ok := callerCache.Load(pc); ok {
+		return v.(*callerInfo)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	info := &callerInfo{file: frame.File, line: frame.Line}
+	if frame.Function == "" {
+		info.fn, info.function = "???", "???"
+	} else {
+		slash := strings.LastIndex(frame.Function, "/")
+		info.fn = frame.Function[slash+1:]
+		//包名中不含.，函数名可能含有.，如(*T).Method、Func.func1
+		if dot := strings.IndexByte(info.fn, '.'); dot >= 0 {
+			info.pkg = frame.Function[:slash+1+dot]
+			info.function = info.fn[dot+1:]
+		} else {
+			info.function = info.fn
+		}
+	}
+	base := info.file[strings.LastIndex(info.file, "/")+1:]
+	info.short = base
+	if dir := strings.LastIndex(info.file, "/"); dir > 0 {
+		info.short = info.file[strings.LastIndex(info.file[:dir], "/")+1:]
+	}
+	info.module = modulePath(info.pkg, base)
+	callerCache.Store(pc, info)
+	return info
+}
+
+// modulePath 由包路径推出相对于主模块的文件路径，不依赖编译机器上的目录
+func modulePath(pkg, base string) string {
+	buildInfoOnce.Do(func() {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			mainModule, mainPackage = bi.Main.Path, bi.Path
+		}
+	})
+	if pkg == "main" && mainPackage != "" {
+		pkg = mainPackage
+	}
+	switch {
+	case pkg == "":
+		return base
+	case mainModule != "" && pkg == mainModule:
+		return base
+	case mainModule != "" && strings.HasPrefix(pkg, mainModule+"/"):
+		return pkg[len(mainModule)+1:] + "/" + base
+	}
+	return pkg + "/" + base
+}
+
+// setCaller 按配置的路径格式填充调用位置
+func (e *Entry) setCaller(pc uintptr) {
+	info := callerForPC(pc)
+	e.Line, e.Func, e.Package, e.Function = info.line, info.fn, info.pkg, info.function
+	switch e.opt.callerPath {
+	case CallerPathModule:
+		e.File = info.module
+	case CallerPathShort:
+		e.File = info.short
+	default:
+		e.File = info.file
+	}
+}
+
+// appendCallerFile text等单行格式使用，完整路径时只输出文件名
+func (e *Entry) appendCallerFile(buf []byte) []byte {
+	if e.opt == nil || e.opt.callerPath == CallerPathFull {
+		return appendShortFile(buf, e.File)
+	}
+	return append(buf, e.File...)
+}
+
+// AddCallerSkip 返回额外跳过n层调用的子logger，用于封装cuslog的函数
+func (l *logger) AddCallerSkip(n int) *logger {
+	c := l.clone()
+	c.callerSkip += n
+	return c
+}
+
+func AddCallerSkip(n int) *logger {
+	return std.AddCallerSkip(n)
+}
@@ -0,0 +1,185 @@
+package cuslog
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"sync"
+)
+
+// 内置extractor使用的字段名
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+type contextKey int
+
+const (
+	loggerContextKey contextKey = iota
+	fieldsContextKey
+	requestIDContextKey
+	traceContextKey
+)
+
+// ContextExtractor 从ctx中提取需要记录的字段
+type ContextExtractor func(ctx context.Context) Fields
+
+var (
+	extractorMu sync.Mutex
+	extractors  = []ContextExtractor{
+		contextFieldsExtractor,
+		RequestIDExtractor,
+		TraceparentExtractor,
+		PprofLabelsExtractor,
+	}
+)
+
+// RegisterContextExtractor 注册extractor，Ctx按注册顺序提取字段，同名字段后者覆盖前者
+func RegisterContextExtractor(extractor ContextExtractor) {
+	extractorMu.Lock()
+	defer extractorMu.Unlock()
+	//写时复制，正在提取的列表不受影响
+	list := make([]ContextExtractor, len(extractors), len(extractors)+1)
+	copy(list, extractors)
+	extractors = append(list, extractor)
+}
+
+// Ctx 返回携带ctx中字段的子logger
+func (l *logger) Ctx(ctx context.Context) *logger {
+	if ctx == nil {
+		return l
+	}
+	extractorMu.Lock()
+	list := extractors
+	extractorMu.Unlock()
+	var fs []field
+	for _, extractor := range list {
+		fields := extractor(ctx)
+		for _, k := range sortedKeys(fields) {
+			fs = append(fs, field{key: k, value: fields[k]})
+		}
+	}
+	if len(fs) == 0 {
+		return l
+	}
+	return l.withFields(fs...)
+}
+
+func Ctx(ctx context.Context) *logger {
+	return std.Ctx(ctx)
+}
+
+// NewContext 返回保存了logger的ctx
+func NewContext(ctx context.Context, l *logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, l)
+}
+
+// FromContext 返回ctx中保存的logger，没有时返回std logger
+func FromContext(ctx context.Context) *logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey).(*logger); ok {
+			return l
+		}
+	}
+	return std
+}
+
+// L 返回ctx中保存的logger，并携带ctx中的字段
+func L(ctx context.Context) *logger {
+	return FromContext(ctx).Ctx(ctx)
+}
+
+// ContextWithFields 返回携带额外字段的ctx，与ctx中已有字段合并
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	merged := make(Fields, len(fields))
+	if exist, ok := ctx.Value(fieldsContextKey).(Fields); ok {
+		for k, v := range exist {
+			merged[k] = v
+		}
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsContextKey, merged)
+}
+
+func contextFieldsExtractor(ctx context.Context) Fields {
+	fields, _ := ctx.Value(fieldsContextKey).(Fields)
+	return fields
+}
+
+// ContextWithRequestID 返回携带请求ID的ctx
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// RequestIDExtractor 提取ContextWithRequestID设置的请求ID
+func RequestIDExtractor(ctx context.Context) Fields {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return Fields{RequestIDKey: id}
+	}
+	return nil
+}
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithTraceparent 解析W3C traceparent请求头，格式为 version-traceid-spanid-flags，无效时返回原ctx
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		!isHexID(parts[1], 32) || !isHexID(parts[2], 16) || len(parts[3]) != 2 {
+		return ctx
+	}
+	//version 00 只允许4段
+	if parts[0] == "00" && len(parts) != 4 {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey, traceContext{traceID: parts[1], spanID: parts[2]})
+}
+
+// isHexID 长度为n的小写十六进制串且不全为0
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	nonZero := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+		nonZero = nonZero || c != '0'
+	}
+	return nonZero
+}
+
+// TraceparentExtractor 提取ContextWithTraceparent设置的trace id和span id
+func TraceparentExtractor(ctx context.Context) Fields {
+	if tc, ok := ctx.Value(traceContextKey).(traceContext); ok {
+		return Fields{TraceIDKey: tc.traceID, SpanIDKey: tc.spanID}
+	}
+	return nil
+}
+
+// PprofLabelsExtractor 提取pprof.WithLabels设置的goroutine标签
+func PprofLabelsExtractor(ctx context.Context) Fields {
+	var fields Fields
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		if fields == nil {
+			fields = Fields{}
+		}
+		fields[key] = value
+		return true
+	})
+	return fields
+}
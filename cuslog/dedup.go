@@ -0,0 +1,249 @@
+package cuslog
+
+import (
+	"hash/maphash"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DedupKey 判断重复时比较的内容
+type DedupKey uint8
+
+const (
+	DedupLevel DedupKey = 1 << iota
+	DedupMessage
+	// DedupCaller 文件、行号
+	DedupCaller
+	// DedupName logger名称
+	DedupName
+)
+
+// 汇总日志中的字段名
+const (
+	dedupRepeatedKey = "repeated"
+	dedupFirstKey    = "first_time"
+	dedupLastKey     = "last_time"
+)
+
+// DefaultDedupWindow 未指定窗口时，重复持续超过该时长也会输出一次汇总
+const DefaultDedupWindow = time.Minute
+
+type dedupOptions struct {
+	window   time.Duration
+	windowed bool
+	keys     DedupKey
+	fields   []string
+}
+
+type DedupOption func(options2 *dedupOptions)
+
+// WithDedupWindow 第一条日志写出后window内的重复日志被合并，为0时只要连续重复就一直合并
+func WithDedupWindow(window time.Duration) DedupOption {
+	return DedupOption(func(options2 *dedupOptions) {
+		options2.window = window
+	})
+}
+
+// WithDedupWindowed 窗口内不连续的重复日志也会被合并，默认只合并连续的重复日志
+func WithDedupWindowed() DedupOption {
+	return DedupOption(func(options2 *dedupOptions) {
+		options2.windowed = true
+	})
+}
+
+// WithDedupKeys 默认比较级别、日志内容和调用位置
+func WithDedupKeys(keys DedupKey) DedupOption {
+	return DedupOption(func(options2 *dedupOptions) {
+		options2.keys = keys
+	})
+}
+
+// WithDedupFields 额外比较指定字段的值
+func WithDedupFields(fields ...string) DedupOption {
+	return DedupOption(func(options2 *dedupOptions) {
+		options2.fields = fields
+	})
+}
+
+// WithDedup 合并重复的日志，只写出第一条，重复的日志仍会触发hook；之后输出一条"last message repeated N times over Xs"的汇总，
+// 汇总带有重复的次数和第一次、最后一次重复的时间；Flush和Close时输出未完成的汇总
+func WithDedup(opts ...DedupOption) Option {
+	o := dedupOptions{window: DefaultDedupWindow, keys: DedupLevel | DedupMessage | DedupCaller}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.windowed && o.window <= 0 {
+		o.window = DefaultDedupWindow
+	}
+	d := &deduper{opt: o, seed: maphash.MakeSeed(), runs: make(map[uint64]*dedupRun)}
+	return Option(func(options2 *options) {
+		options2.dedup = d
+	})
+}
+
+// dedupRun 同一key的一次重复
+type dedupRun struct {
+	started time.Time
+	count   uint64
+	first   time.Time
+	last    time.Time
+	level   Level
+	name    string
+	file    string
+	line    int
+	fn      string
+}
+
+type deduper struct {
+	opt  dedupOptions
+	seed maphash.Seed
+
+	mu        sync.Mutex
+	runs      map[uint64]*dedupRun
+	lastSweep time.Time
+}
+
+// admit 返回false表示e是重复的日志，不需要写出；需要输出的汇总在释放锁之后写出
+func (d *deduper) admit(e *Entry) bool {
+	key, now := d.key(e), e.Time
+	var pending []dedupRun
+	d.mu.Lock()
+	r := d.runs[key]
+	if r != nil && (d.opt.window <= 0 || now.Sub(r.started) < d.opt.window) {
+		if r.count == 0 {
+			r.first = now
+		}
+		r.count++
+		r.last = now
+		d.mu.Unlock()
+		return false
+	}
+	if r != nil && r.count > 0 {
+		pending = append(pending, *r)
+	}
+	if !d.opt.windowed {
+		//只合并连续的重复，出现不同的日志时结束之前的重复
+		for k, other := range d.runs {
+			if k != key && other.count > 0 {
+				pending = append(pending, *other)
+			}
+			delete(d.runs, k)
+		}
+	} else if now.Sub(d.lastSweep) >= d.opt.window {
+		for k, other := range d.runs {
+			if k != key && now.Sub(other.started) >= d.opt.window {
+				if other.count > 0 {
+					pending = append(pending, *other)
+				}
+				delete(d.runs, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.runs[key] = &dedupRun{started: now, level: e.Level, name: e.Name, file: e.File, line: e.Line, fn: e.Func}
+	d.mu.Unlock()
+	for i := range pending {
+		e.logger.writeDedupSummary(&pending[i])
+	}
+	return true
+}
+
+// flush 输出所有未完成的汇总，之后的重复重新计数
+func (d *deduper) flush(l *logger) {
+	var pending []dedupRun
+	d.mu.Lock()
+	for _, r := range d.runs {
+		if r.count > 0 {
+			pending = append(pending, *r)
+			r.count = 0
+		}
+	}
+	d.mu.Unlock()
+	for i := range pending {
+		l.writeDedupSummary(&pending[i])
+	}
+}
+
+func (d *deduper) key(e *Entry) uint64 {
+	var h maphash.Hash
+	h.SetSeed(d.seed)
+	keys := d.opt.keys
+	if keys&DedupLevel != 0 {
+		h.WriteByte(byte(e.Level))
+	}
+	if keys&DedupMessage != 0 {
+		h.Write(e.messageBytes())
+		h.WriteByte(0)
+	}
+	if keys&DedupCaller != 0 {
+		h.WriteString(e.File)
+		h.WriteByte(0)
+		var line [8]byte
+		h.Write(strconv.AppendInt(line[:0], int64(e.Line), 10))
+	}
+	if keys&DedupName != 0 {
+		h.WriteString(e.Name)
+		h.WriteByte(0)
+	}
+	if len(d.opt.fields) > 0 {
+		var buf [64]byte
+		for _, f := range d.opt.fields {
+			h.Write(appendValue(buf[:0], e.Map[f]))
+			h.WriteByte(0)
+		}
+	}
+	return h.Sum64()
+}
+
+// writeDedupSummary 汇总使用被合并日志的级别、名称和调用位置，不再经过合并；
+// 被合并的日志已经触发过hook，汇总不再触发
+func (l *logger) writeDedupSummary(r *dedupRun) {
+	e := l.entry()
+	e.opt = l.opt.Load()
+	e.Time = time.Now()
+	e.Level, e.Name, e.File, e.Line, e.Func = r.level, r.name, r.file, r.line, r.fn
+	e.Format = FmtEmptySeparate
+	e.argBuf[0] = dedupMessage(r)
+	e.Args = e.argBuf[:]
+	e.setField(dedupRepeatedKey, r.count)
+	e.setField(dedupFirstKey, r.first)
+	e.setField(dedupLastKey, r.last)
+	if e.opt.slogHandler == nil {
+		e.format()
+	}
+	e.emit()
+	e.release()
+}
+
+// dedupMessage 如 last message repeated 4,213 times over 12s
+func dedupMessage(r *dedupRun) string {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, "last message repeated "...)
+	buf = appendGrouped(buf, r.count)
+	if r.count == 1 {
+		buf = append(buf, " time over "...)
+	} else {
+		buf = append(buf, " times over "...)
+	}
+	d := r.last.Sub(r.first)
+	if d >= time.Second {
+		d = d.Round(time.Second)
+	} else {
+		d = d.Round(time.Millisecond)
+	}
+	buf = append(buf, d.String()...)
+	return string(buf)
+}
+
+// appendGrouped 每三位加一个逗号
+func appendGrouped(dst []byte, n uint64) []byte {
+	s := strconv.FormatUint(n, 10)
+	for i := 0; i < len(s); i++ {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, s[i])
+	}
+	return dst
+}
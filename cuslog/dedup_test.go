@@ -0,0 +1,122 @@
+package cuslog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupConsecutive(t *testing.T) {
+	buf := &lockedBuffer{}
+	hook := &recordHook{levels: []Level{ErrorLevel}}
+	l := New(WithOutput(buf), WithDedup(), WithHooks(hook))
+	for i := 0; i < 5; i++ {
+		l.Error("connection refused")
+	}
+	l.Error("connection restored")
+	lines := buf.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[1], "last message repeated 4 times over") || !strings.Contains(lines[1], "repeated=4") ||
+		!strings.Contains(lines[1], "first_time=") || !strings.Contains(lines[1], "last_time=") {
+		t.Errorf("unexpected summary: %q", lines[1])
+	}
+	//摘要使用被合并日志的级别和调用位置
+	if !strings.Contains(lines[1], "ERROR") || !strings.Contains(lines[1], "dedup_test.go") {
+		t.Errorf("summary should keep level and caller: %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "connection restored") {
+		t.Errorf("unexpected line: %q", lines[2])
+	}
+	//重复的日志仍然触发hook，汇总不触发
+	if n := hook.count(); n != 6 {
+		t.Errorf("hook fired %d times, want 6", n)
+	}
+}
+
+func TestDedupFlushAndClose(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDedup())
+	slow := func() { l.Warn("slow query") }
+	for i := 0; i < 3; i++ {
+		slow()
+	}
+	l.Flush()
+	if lines := buf.Lines(); len(lines) != 2 || !strings.Contains(lines[1], "repeated 2 times") {
+		t.Fatalf("flush should write the pending summary: %q", buf.String())
+	}
+	l.Flush()
+	if n := len(buf.Lines()); n != 2 {
+		t.Errorf("second flush wrote again: %q", buf.String())
+	}
+	//flush之后的重复重新计数
+	slow()
+	l.Close()
+	if lines := buf.Lines(); len(lines) != 3 || !strings.Contains(lines[2], "repeated 1 time over") {
+		t.Errorf("close should write the pending summary: %q", buf.String())
+	}
+}
+
+func TestDedupWindowed(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithDedup(WithDedupWindowed(), WithDedupWindow(time.Minute)))
+	for i := 0; i < 3; i++ {
+		l.Info("a")
+		l.Info("b")
+	}
+	if got := buf.Lines(); len(got) != 2 {
+		t.Fatalf("interleaved duplicates should be merged within the window: %q", buf.String())
+	}
+	l.Flush()
+	if got := buf.String(); strings.Count(got, "repeated 2 times") != 2 {
+		t.Errorf("want one summary per message: %q", got)
+	}
+}
+
+func TestDedupWindowExpires(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithDedup(WithDedupWindow(30*time.Millisecond)))
+	l.Info("tick")
+	l.Info("tick")
+	time.Sleep(40 * time.Millisecond)
+	l.Info("tick")
+	lines := buf.Lines()
+	if len(lines) != 3 || !strings.Contains(lines[1], "repeated 1 time") || !strings.HasSuffix(lines[2], "tick") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestDedupKeysAndFields(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDedup(WithDedupKeys(DedupMessage), WithDedupFields("host")))
+	l.WithField("host", "a").Info("down")
+	l.WithField("host", "a").Warn("down")
+	l.WithField("host", "b").Info("down")
+	//不比较级别，只有字段不同的日志才写出
+	if n := len(buf.Lines()); n != 3 {
+		t.Errorf("got %d lines, want 3 (first, summary, host b): %q", n, buf.String())
+	}
+}
+
+func TestDedupConcurrent(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithDedup())
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Error("flapping")
+			}
+		}()
+	}
+	wg.Wait()
+	l.Flush()
+	lines := buf.Lines()
+	if len(lines) != 2 || !strings.Contains(lines[1], "repeated 399 times") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
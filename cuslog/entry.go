@@ -1,67 +1,253 @@
 package cuslog
 
 import (
-	"bytes"
+	"fmt"
+	"io"
 	"runtime"
-	"strings"
+	"sync"
 	"time"
 )
 
 type Entry struct {
 	logger *logger
-	Buffer *bytes.Buffer
+	//写入开始时的配置快照，整条entry使用同一份配置
+	opt    *options
+	Buffer []byte
 	Map    map[string]interface{}
 	Level  Level
+	Name   string
 	Time   time.Time
 	File   string
 	Line   int
-	Func   string
+	//Func 为"包名.函数名"，Package为完整包路径，Function为不含包名的函数名
+	Func     string
+	Package  string
+	Function string
+	// Stack 达到WithStacktraceLevel级别时记录的调用栈，不含cuslog内部的帧
+	Stack  []Frame
 	Format string
 	Args   []interface{}
+	keys   []string
+
+	//缓存格式化后的日志内容，多个formatter只格式化一次
+	message    []byte
+	hasMessage bool
+
+	//buffers[0]为默认buffer，其余供多个appender的formatter使用
+	buffers   [][]byte
+	formatted []bool
+	pcs       [1]uintptr
+	skip      int
+	argBuf    [1]interface{}
+	//flight recorder补写的entry，不受appender级别过滤
+	flight bool
 }
 
+// maxPooledBufferSize 超过该大小的buffer不放回池中，避免偶发的大日志长期占用内存
+const maxPooledBufferSize = 64 << 10
+
 func entry(logger *logger) *Entry {
-	return &Entry{logger: logger, Buffer: new(bytes.Buffer), Map: make(map[string]interface{}, 5)}
+	return &Entry{logger: logger, buffers: [][]byte{make([]byte, 0, 512)}, Map: make(map[string]interface{}, 5)}
 }
 
 func (e *Entry) write(level Level, format string, args ...interface{}) {
-	if e.logger.opt.level > level {
+	e.opt = e.logger.opt.Load()
+	//Callers(3)表示跳过3级调用，在本例中call->debug->write：Callers->0,write->1,debug->2,调用函数->3
+	//e.skip为cuslog内部额外的调用层数，callerSkip为使用方封装的层数
+	skip := 3 + e.skip + e.opt.callerSkip + e.logger.callerSkip
+	if skip < 3 {
+		skip = 3
+	}
+	allowed := level.allowed(e.logger.level(e.opt))
+	if !allowed && e.opt.flight == nil {
+		e.release()
 		return
 	}
 	e.Time = time.Now()
 	e.Level = level
+	e.Name = e.logger.name
+	if e.Name == "" {
+		e.Name = e.opt.name
+	}
 	e.Format = format
 	e.Args = args
-	if !e.logger.opt.disableCaller {
-		//获取函数堆栈信息，返回函数指针、文件路径、行号、是否获取信息成功；
-		//Caller(2)表示获取调用调用该函数的2级调用，在本例中call->debug->write：write->0,debug->1,调用函数->2
-		if pc, file, line, ok := runtime.Caller(2); !ok {
+	for _, f := range e.logger.fields {
+		e.setField(f.key, f.value)
+	}
+	if !allowed {
+		//被过滤的日志只记录调用位置的PC，补写时再解析
+		if !e.opt.disableCaller {
+			runtime.Callers(skip, e.pcs[:])
+		}
+		e.recordFlight(false)
+		e.release()
+		return
+	}
+	if e.opt.flight != nil && level >= e.opt.flight.trigger {
+		e.logger.dumpFlight(e.logger.flightRing(e.opt))
+	}
+	if !e.opt.disableCaller && level >= e.opt.callerLevel {
+		//获取函数堆栈信息，runtime.Caller每次调用都有内存分配，改用Callers写入entry自带的数组
+		if runtime.Callers(skip, e.pcs[:]) > 0 {
+			e.setCaller(e.pcs[0])
+		} else {
 			e.File = "???"
 			e.Func = "???"
-		} else {
-			e.File, e.Line, e.Func = file, line, runtime.FuncForPC(pc).Name()
-			//获取调用名称，取消路径
-			e.Func = e.Func[strings.LastIndex(e.Func, "/")+1:]
 		}
 	}
-	//使用formatter进行显示，默认
-	e.format()
-	e.writer()
+	if e.opt.stacktrace && level >= e.opt.stacktraceLevel {
+		e.captureStack(skip)
+	}
+	if e.opt.flight != nil {
+		e.recordFlight(true)
+	}
+	e.output()
+}
+
+// output 替换敏感数据后格式化、触发hook，合并重复的日志后写出，最后将entry放回池中
+//
+// 重复的日志同样触发hook，合并只影响写出
+func (e *Entry) output() {
+	if e.opt.redactor != nil {
+		e.opt.redactor.Redact(e)
+	}
+	if e.opt.slogHandler == nil {
+		//使用formatter进行显示，默认
+		e.format()
+	}
+	e.fireHooks()
+	if e.opt.dedup == nil || e.opt.dedup.admit(e) {
+		e.emit()
+	}
 	e.release()
 }
 
+// emit 写出已格式化的entry，使用WithSlogHandler时交给slog.Handler
+func (e *Entry) emit() {
+	if e.opt.slogHandler != nil {
+		e.handleSlog()
+		return
+	}
+	e.writer()
+}
+
 func (e *Entry) format() {
-	_ = e.logger.opt.formatter.Format(e)
+	if len(e.opt.appenders) > 0 {
+		e.formatAppenders()
+		return
+	}
+	buf, err := e.opt.formatter.Format(e.buffers[0][:0], e)
+	e.buffers[0], e.Buffer = buf, buf
+	if err != nil {
+		//formatter有输出时照常写出，只在没有输出时写入备用输出
+		e.handleError(err, len(buf) == 0)
+	}
 }
 
 func (e *Entry) writer() {
-	e.logger.mu.Lock()
-	_, _ = e.logger.opt.output.Write(e.Buffer.Bytes())
-	e.logger.mu.Unlock()
+	if len(e.opt.appenders) > 0 {
+		e.writeAppenders()
+		return
+	}
+	e.writeTo(e.opt.output, e.opt.outputMu, e.Buffer)
+}
+
+// writeTo 某个output写入失败不影响其他output
+func (e *Entry) writeTo(output io.Writer, mu *sync.Mutex, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	opt := e.opt
+	if opt.async != nil && opt.async.write(opt, output, mu, data, opt.asyncBypass && e.Level >= opt.asyncBypassLevel) {
+		return
+	}
+	mu.Lock()
+	n, err := output.Write(data)
+	mu.Unlock()
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		e.handleError(err, true)
+	}
 }
 
 func (e *Entry) release() {
-	e.Args, e.Line, e.File, e.Format, e.Func = nil, 0, "", "", ""
-	e.Buffer.Reset()
+	e.Args, e.Line, e.File, e.Format, e.Func, e.Name = nil, 0, "", "", "", ""
+	e.Package, e.Function = "", ""
+	e.Stack = e.Stack[:0]
+	//清空字段，避免复用entry时泄漏到下一次输出
+	for k := range e.Map {
+		delete(e.Map, k)
+	}
+	e.keys = e.keys[:0]
+	for i, buf := range e.buffers {
+		if cap(buf) > maxPooledBufferSize {
+			buf = make([]byte, 0, 512)
+		}
+		e.buffers[i] = buf[:0]
+	}
+	if cap(e.message) > maxPooledBufferSize {
+		e.message = nil
+	}
+	e.message, e.hasMessage = e.message[:0], false
+	e.Buffer, e.opt = nil, nil
+	e.pcs[0], e.argBuf[0], e.skip, e.flight = 0, nil, 0, false
 	e.logger.entryPool.Put(e)
 }
+
+// AppendMessage 追加格式化后的日志内容，结果在entry内缓存
+func (e *Entry) AppendMessage(dst []byte) []byte {
+	return append(dst, e.messageBytes()...)
+}
+
+func (e *Entry) messageBytes() []byte {
+	if !e.hasMessage {
+		e.message, e.hasMessage = e.appendMessage(e.message[:0]), true
+	}
+	return e.message
+}
+
+func (e *Entry) appendMessage(dst []byte) []byte {
+	if e.Format != FmtEmptySeparate {
+		return fmt.Appendf(dst, e.Format, e.Args...)
+	}
+	//最常见的单个字符串参数直接追加
+	if len(e.Args) == 1 {
+		if s, ok := e.Args[0].(string); ok {
+			return append(dst, s...)
+		}
+	}
+	return fmt.Append(dst, e.Args...)
+}
+
+func (e *Entry) setField(key string, value interface{}) {
+	if _, ok := e.Map[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.Map[key] = value
+}
+
+// Keys 按添加顺序返回Map中的字段名，直接写入Map的字段按key排序追加在后
+func (e *Entry) Keys() []string {
+	if len(e.keys) != len(e.Map) {
+		seen := make(map[string]bool, len(e.keys))
+		for _, k := range e.keys {
+			seen[k] = true
+		}
+		extra := make(map[string]interface{})
+		for k := range e.Map {
+			if !seen[k] {
+				extra[k] = nil
+			}
+		}
+		keys := e.keys[:0]
+		for _, k := range e.keys {
+			if _, ok := e.Map[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		e.keys = append(keys, sortedKeys(extra)...)
+	}
+	return e.keys
+}
@@ -0,0 +1,111 @@
+package cuslog
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorHandler 处理formatter和output返回的错误，异步写入失败时e为nil
+//
+// 不要在handler中使用同一个logger写日志，output持续失败时会无限递归
+type ErrorHandler func(err error, e *Entry)
+
+// fallback输出的限流：每个周期最多输出fallbackBurst行，超出的只计数
+const (
+	fallbackInterval = time.Second
+	fallbackBurst    = 10
+)
+
+// fallbackWriter formatter没有输出或output写入失败时，写入只包含基本信息的一行
+type fallbackWriter struct {
+	output io.Writer
+	errors uint64
+
+	mu          sync.Mutex
+	windowStart time.Time
+	written     int
+	suppressed  uint64
+}
+
+func newFallbackWriter(output io.Writer) *fallbackWriter {
+	return &fallbackWriter{output: output}
+}
+
+// WithErrorHandler 设置formatter和output出错时的回调
+func WithErrorHandler(handler ErrorHandler) Option {
+	return Option(func(options2 *options) {
+		options2.errorHandler = handler
+	})
+}
+
+// WithFallbackOutput formatter或output失败时的备用输出，默认os.Stderr，io.Discard表示不输出
+func WithFallbackOutput(output io.Writer) Option {
+	return Option(func(options2 *options) {
+		options2.fallback = newFallbackWriter(output)
+	})
+}
+
+func (f *fallbackWriter) write(line []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if now.Sub(f.windowStart) >= fallbackInterval {
+		if f.suppressed > 0 {
+			msg := strconv.AppendUint([]byte("cuslog: suppressed "), f.suppressed, 10)
+			msg = append(msg, " fallback lines\n"...)
+			_, _ = f.output.Write(msg)
+		}
+		f.windowStart, f.written, f.suppressed = now, 0, 0
+	}
+	if f.written >= fallbackBurst {
+		f.suppressed++
+		return
+	}
+	f.written++
+	_, _ = f.output.Write(line)
+}
+
+// handleError 计数并调用ErrorHandler，fallback为true时写入备用输出
+func (e *Entry) handleError(err error, fallback bool) {
+	opt := e.opt
+	atomic.AddUint64(&opt.fallback.errors, 1)
+	if opt.errorHandler != nil {
+		opt.errorHandler(err, e)
+	}
+	if fallback {
+		opt.fallback.write(e.appendFallback(nil, err))
+	}
+}
+
+// appendFallback 不经过formatter，只输出时间、级别、日志内容和错误
+func (e *Entry) appendFallback(buf []byte, err error) []byte {
+	buf = e.Time.AppendFormat(buf, time.RFC3339)
+	buf = append(buf, ' ')
+	buf = append(buf, e.Level.String()...)
+	buf = append(buf, ' ')
+	buf = e.AppendMessage(buf)
+	buf = append(buf, " cuslog_error="...)
+	buf = strconv.AppendQuote(buf, err.Error())
+	return append(buf, '\n')
+}
+
+// handleAsyncError 异步写入时entry已经放回池中，将已格式化的数据写入备用输出
+func (o *options) handleAsyncError(err error, data []byte) {
+	atomic.AddUint64(&o.fallback.errors, 1)
+	if o.errorHandler != nil {
+		o.errorHandler(err, nil)
+	}
+	o.fallback.write(data)
+}
+
+// ErrorCount 返回formatter和output出错的次数
+func (l *logger) ErrorCount() uint64 {
+	return atomic.LoadUint64(&l.opt.Load().fallback.errors)
+}
+
+func ErrorCount() uint64 {
+	return std.ErrorCount()
+}
@@ -24,4 +24,22 @@ func main() {
 	)
 	l.Info("custom log with json formatter")
 	l.Debug("custom log with json formatter 2")
+
+	// 携带结构化字段
+	l.WithField("tenant", "acme").WithFields(cuslog.Fields{"request_id": "r-1"}).Info("custom log with fields")
+
+	// 按大小和天切割的文件
+	rw, err := cuslog.NewRotateWriter("logs/app-%Y%m%d.log",
+		cuslog.WithRotateMaxSize(100<<20),
+		cuslog.WithRotateInterval(cuslog.RotateDaily),
+		cuslog.WithRotateCompress(true),
+		cuslog.WithRotateMaxBackups(7),
+	)
+	if err != nil {
+		log.Fatalln("create rotate writer failed")
+	}
+	defer rw.Close()
+
+	rl := cuslog.New(cuslog.WithOutput(rw))
+	rl.Info("custom log with rotate writer")
 }
@@ -0,0 +1,60 @@
+package cuslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	exitMu       sync.Mutex
+	exitHandlers []func()
+)
+
+// RegisterExitHandler 注册Fatal退出前执行的清理函数，按注册顺序执行
+func RegisterExitHandler(handler func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHandlers = append(exitHandlers, handler)
+}
+
+func runExitHandlers() {
+	exitMu.Lock()
+	handlers := make([]func(), len(exitHandlers))
+	copy(handlers, exitHandlers)
+	exitMu.Unlock()
+	for _, handler := range handlers {
+		runExitHandler(handler)
+	}
+}
+
+// runExitHandler 某个清理函数panic不影响后续清理和退出
+func runExitHandler(handler func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "cuslog: exit handler panic:", err)
+		}
+	}()
+	handler()
+}
+
+// exit 写出所有output，执行清理函数后退出
+func (l *logger) exit(code int) {
+	l.Close()
+	runExitHandlers()
+	exitFunc := l.opt.Load().exitFunc
+	if exitFunc == nil {
+		exitFunc = os.Exit
+	}
+	exitFunc(code)
+}
+
+// panic 写出所有output后panic
+func (l *logger) panic(msg string) {
+	l.Flush()
+	panic(msg)
+}
+
+func (l *logger) development() bool {
+	return l.opt.Load().development
+}
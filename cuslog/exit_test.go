@@ -0,0 +1,117 @@
+package cuslog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer 可并发写入的bytes.Buffer，异步写入时后台goroutine也会写入
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *lockedBuffer) Lines() []string {
+	s := strings.TrimRight(b.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// resetExitHandlers 清空全局的清理函数，测试结束后恢复
+func resetExitHandlers(t *testing.T) {
+	exitMu.Lock()
+	saved := exitHandlers
+	exitHandlers = nil
+	exitMu.Unlock()
+	t.Cleanup(func() {
+		exitMu.Lock()
+		exitHandlers = saved
+		exitMu.Unlock()
+	})
+}
+
+func TestFatalRunsHandlersThenExits(t *testing.T) {
+	resetExitHandlers(t)
+	buf := &lockedBuffer{}
+	var calls []string
+	l := New(WithOutput(buf), WithAsync(16, AsyncBlock), WithExitFunc(func(code int) {
+		calls = append(calls, "exit")
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+		//退出前异步队列中的日志已经写出
+		if !strings.Contains(buf.String(), "fatal error: disk full") {
+			t.Errorf("fatal entry not flushed before exit: %q", buf.String())
+		}
+	}))
+	RegisterExitHandler(func() { calls = append(calls, "first") })
+	RegisterExitHandler(func() { calls = append(calls, "second") })
+
+	l.Fatalf("fatal error: %s", "disk full")
+	if got := strings.Join(calls, ","); got != "first,second,exit" {
+		t.Errorf("calls = %s, want first,second,exit", got)
+	}
+}
+
+func TestExitHandlerPanicRecovered(t *testing.T) {
+	resetExitHandlers(t)
+	var calls []string
+	l := New(WithOutput(&lockedBuffer{}), WithExitFunc(func(int) { calls = append(calls, "exit") }))
+	RegisterExitHandler(func() { panic("boom") })
+	RegisterExitHandler(func() { calls = append(calls, "after") })
+
+	l.Fatal("stop")
+	if got := strings.Join(calls, ","); got != "after,exit" {
+		t.Errorf("calls = %s, want after,exit", got)
+	}
+}
+
+func TestPanicWritesThenPanics(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithAsync(16, AsyncBlock))
+	defer func() {
+		r := recover()
+		if r != "invalid state 3" {
+			t.Errorf("recovered %v, want invalid state 3", r)
+		}
+		if !strings.Contains(buf.String(), "PANIC") || !strings.Contains(buf.String(), "invalid state 3") {
+			t.Errorf("panic entry not written: %q", buf.String())
+		}
+	}()
+	l.Panicf("invalid state %d", 3)
+	t.Error("Panicf returned")
+}
+
+func TestDPanic(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	l.DPanic("unexpected")
+	if !strings.Contains(buf.String(), "DPANIC") {
+		t.Errorf("dpanic entry not written: %q", buf.String())
+	}
+
+	dev := New(WithOutput(buf), WithDevelopment(true))
+	defer func() {
+		if r := recover(); r != "unexpected nil" {
+			t.Errorf("recovered %v, want unexpected nil", r)
+		}
+	}()
+	dev.DPanicf("unexpected %s", "nil")
+	t.Error("DPanicf returned in development mode")
+}
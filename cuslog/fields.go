@@ -0,0 +1,83 @@
+package cuslog
+
+const (
+	// ErrorKey WithError 使用的字段名
+	ErrorKey = "error"
+)
+
+// Fields 结构化字段，key为字段名
+type Fields map[string]interface{}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// WithField 返回携带指定字段的子logger，原logger不受影响
+func (l *logger) WithField(key string, value interface{}) *logger {
+	return l.withFields(field{key: key, value: value})
+}
+
+// WithFields 返回携带一组字段的子logger，map无序，按key排序后追加
+func (l *logger) WithFields(fields Fields) *logger {
+	keys := sortedKeys(fields)
+	fs := make([]field, 0, len(keys))
+	for _, k := range keys {
+		fs = append(fs, field{key: k, value: fields[k]})
+	}
+	return l.withFields(fs...)
+}
+
+// WithError 以ErrorKey为字段名记录err
+func (l *logger) WithError(err error) *logger {
+	return l.withFields(field{key: ErrorKey, value: err})
+}
+
+func (l *logger) withFields(fs ...field) *logger {
+	child := l.clone()
+	//复制父logger的字段，保证父子logger互不影响
+	child.fields = make([]field, len(l.fields), len(l.fields)+len(fs))
+	copy(child.fields, l.fields)
+	for _, f := range fs {
+		child.fields = setField(child.fields, f)
+	}
+	return child
+}
+
+// setField 已存在的key原位替换，保持字段顺序
+func setField(fs []field, f field) []field {
+	for i := range fs {
+		if fs[i].key == f.key {
+			fs[i].value = f.value
+			return fs
+		}
+	}
+	return append(fs, f)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	//字段数量通常很少，插入排序即可
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+	return keys
+}
+
+// std logger
+func WithField(key string, value interface{}) *logger {
+	return std.WithField(key, value)
+}
+
+func WithFields(fields Fields) *logger {
+	return std.WithFields(fields)
+}
+
+func WithError(err error) *logger {
+	return std.WithError(err)
+}
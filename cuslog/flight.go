@@ -0,0 +1,193 @@
+package cuslog
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// FlightKey 由flight recorder补写的日志带有该字段
+const FlightKey = "flight_recorder"
+
+// flightRecord 记录时格式化日志内容并复制字段，调用方之后修改参数不影响补写的内容
+type flightRecord struct {
+	time    time.Time
+	level   Level
+	name    string
+	message string
+	fields  []field
+	pc      uintptr
+	//已经写出过的日志只占位保持顺序，补写时跳过
+	written bool
+}
+
+// flightRing 固定大小的环形缓冲区
+type flightRing struct {
+	mu      sync.Mutex
+	records []flightRecord
+	next    int
+	full    bool
+}
+
+func newFlightRing(size int) *flightRing {
+	if size <= 0 {
+		size = 1
+	}
+	return &flightRing{records: make([]flightRecord, size)}
+}
+
+type flightRecorder struct {
+	size    int
+	trigger Level
+	ring    *flightRing
+}
+
+// WithFlightRecorder 在内存中保留最近size条各个级别的日志，不低于trigger的日志写出前先补写其中被级别过滤的日志，
+// 补写的日志带有FlightKey字段；已经写出过的日志只占用缓冲区的位置，不会重复写出
+func WithFlightRecorder(size int, trigger Level) Option {
+	fr := &flightRecorder{size: size, trigger: trigger, ring: newFlightRing(size)}
+	return Option(func(options2 *options) {
+		options2.flight = fr
+	})
+}
+
+func (r *flightRing) add(rec flightRecord) {
+	r.mu.Lock()
+	r.records[r.next] = rec
+	if r.next++; r.next == len(r.records) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+}
+
+// take 按时间顺序取出所有记录并清空
+func (r *flightRing) take() []flightRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []flightRecord
+	if r.full {
+		out = append(out, r.records[r.next:]...)
+	}
+	out = append(out, r.records[:r.next]...)
+	for i := range r.records {
+		r.records[i] = flightRecord{}
+	}
+	r.next, r.full = 0, false
+	return out
+}
+
+// flightRing 使用FlightScope创建的缓冲区，没有时使用全局的缓冲区
+func (l *logger) flightRing(opt *options) *flightRing {
+	if l.flight != nil {
+		return l.flight
+	}
+	return opt.flight.ring
+}
+
+// FlightScope 返回使用独立缓冲区的子logger，用于单个goroutine或请求，避免并发请求的日志互相穿插；
+// 没有开启flight recorder时返回l
+func (l *logger) FlightScope() *logger {
+	opt := l.opt.Load()
+	if opt.flight == nil {
+		return l
+	}
+	c := l.clone()
+	c.flight = newFlightRing(opt.flight.size)
+	return c
+}
+
+// ContextWithFlightScope 在ctx中保存FromContext(ctx).FlightScope()，之后通过L(ctx)使用
+func ContextWithFlightScope(ctx context.Context) context.Context {
+	return NewContext(ctx, FromContext(ctx).FlightScope())
+}
+
+// DumpFlightRecorder 补写缓冲区中的日志并清空
+func (l *logger) DumpFlightRecorder() {
+	opt := l.opt.Load()
+	if opt.flight == nil {
+		return
+	}
+	l.dumpFlight(l.flightRing(opt))
+}
+
+func DumpFlightRecorder() {
+	std.DumpFlightRecorder()
+}
+
+// DumpFlightRecorderOnSignal 收到sig时补写l的全局缓冲区，返回停止监听的函数
+func DumpFlightRecorderOnSignal(l *logger, sig ...os.Signal) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if opt := l.opt.Load(); opt.flight != nil {
+					l.dumpFlight(opt.flight.ring)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// recordFlight 保存被过滤的e的副本，written表示e会被写出，只记录占位
+func (e *Entry) recordFlight(written bool) {
+	if written {
+		e.logger.flightRing(e.opt).add(flightRecord{written: true})
+		return
+	}
+	rec := flightRecord{time: e.Time, level: e.Level, name: e.Name, message: string(e.messageBytes()), pc: e.pcs[0]}
+	if keys := e.Keys(); len(keys) > 0 {
+		rec.fields = make([]field, len(keys))
+		for i, k := range keys {
+			rec.fields[i] = field{key: k, value: flightValue(e.Map[k])}
+		}
+	}
+	e.logger.flightRing(e.opt).add(rec)
+}
+
+// flightValue 不可变的值原样保存，其他值转为字符串，避免补写时读到修改后的内容
+func flightValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Time, time.Duration, error:
+		return v
+	}
+	return string(appendValue(nil, v))
+}
+
+// dumpFlight 补写的日志不受logger和appender的级别过滤
+func (l *logger) dumpFlight(ring *flightRing) {
+	for _, rec := range ring.take() {
+		if rec.written {
+			continue
+		}
+		e := l.entry()
+		e.opt = l.opt.Load()
+		e.flight = true
+		e.Time, e.Level, e.Name = rec.time, rec.level, rec.name
+		e.Format, e.argBuf[0] = FmtEmptySeparate, rec.message
+		e.Args = e.argBuf[:]
+		for _, f := range rec.fields {
+			e.setField(f.key, f.value)
+		}
+		e.setField(FlightKey, true)
+		if rec.pc != 0 {
+			e.pcs[0] = rec.pc
+			e.setCaller(rec.pc)
+		}
+		e.output()
+	}
+}
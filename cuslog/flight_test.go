@@ -0,0 +1,119 @@
+package cuslog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFlightRecorderSnapshotsArgs(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithLevel(InfoLevel), WithFlightRecorder(8, ErrorLevel))
+	state := []byte("state=A")
+	l.Debugf("%s", state)
+	copy(state, "state=B")
+	l.WithField("payload", map[string]int{"n": 1}).Debug("with field")
+	l.Error("failure")
+	out := buf.String()
+	if !strings.Contains(out, "state=A") || strings.Contains(out, "state=B") {
+		t.Errorf("dump should show the args at record time: %q", out)
+	}
+}
+
+func TestFlightRecorderAllLevels(t *testing.T) {
+	buf := &lockedBuffer{}
+	hook := &recordHook{levels: []Level{InfoLevel, DebugLevel}}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel),
+		WithFlightRecorder(8, ErrorLevel), WithHooks(hook))
+	l.Info("started")
+	l.Debug("detail")
+	l.Error("failure")
+	lines := buf.Lines()
+	//已经写出的started不再补写
+	want := []string{"started", "detail flight_recorder=true", "failure"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", buf.String())
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("line %d = %q, want suffix %q", i, lines[i], w)
+		}
+	}
+	if got := strings.Join(hook.messages, ","); got != "started,detail" {
+		t.Errorf("hook fired for %s, want started,detail", got)
+	}
+
+	//连续的错误只写出一次，不补写上一条触发日志
+	l.Error("again")
+	if lines = buf.Lines(); len(lines) != 4 || !strings.HasSuffix(lines[3], "again") {
+		t.Errorf("unexpected second dump: %q", buf.String())
+	}
+}
+
+// 开启flight recorder时重复的错误仍然被合并
+func TestFlightRecorderWithDedup(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel),
+		WithFlightRecorder(8, ErrorLevel), WithDedup())
+	l.Debug("detail")
+	for i := 0; i < 3; i++ {
+		l.Error("storm")
+	}
+	l.Flush()
+	lines := buf.Lines()
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "detail flight_recorder=true") ||
+		!strings.HasSuffix(lines[1], "storm") || !strings.Contains(lines[2], "repeated 2 times") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestFlightRecorderRingSize(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel), WithFlightRecorder(2, ErrorLevel))
+	for _, msg := range []string{"d1", "d2", "d3"} {
+		l.Debug(msg)
+	}
+	l.DumpFlightRecorder()
+	if got := buf.Lines(); len(got) != 2 || !strings.Contains(got[0], "d2") || !strings.Contains(got[1], "d3") {
+		t.Errorf("want the last two entries, got %q", buf.String())
+	}
+}
+
+func TestFlightRecorderScopes(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel), WithFlightRecorder(8, ErrorLevel))
+	a := L(ContextWithFlightScope(NewContext(context.Background(), l)))
+	b := l.FlightScope()
+	var wg sync.WaitGroup
+	for _, s := range []struct {
+		l    *logger
+		name string
+	}{{a, "a"}, {b, "b"}} {
+		wg.Add(1)
+		go func(sl *logger, name string) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				sl.Debug("request " + name)
+			}
+		}(s.l, s.name)
+	}
+	wg.Wait()
+	b.Error("b failed")
+	if got := buf.String(); strings.Contains(got, "request a") || !strings.Contains(got, "request b") {
+		t.Errorf("scope b should only dump its own entries: %q", got)
+	}
+}
+
+func TestFlightRecorderSlog(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel), WithFlightRecorder(8, ErrorLevel))
+	sl := slog.New(NewSlogHandler(l))
+	sl.Debug("slog detail", "k", "v")
+	sl.Error("slog failure")
+	lines := buf.Lines()
+	if len(lines) != 2 || !strings.Contains(lines[0], "slog detail") || !strings.Contains(lines[0], "k=v") {
+		t.Errorf("filtered slog entries should be recorded: %q", buf.String())
+	}
+}
@@ -1,5 +1,6 @@
 package cuslog
 
+// Formatter 将entry追加到buf后返回，buf来自entry池，实现时不应持有buf
 type Formatter interface {
-	Format(entry *Entry) error
+	Format(buf []byte, entry *Entry) ([]byte, error)
 }
@@ -0,0 +1,225 @@
+package cuslog
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ColorMode 控制ConsoleFormatter是否输出ANSI颜色
+type ColorMode uint8
+
+const (
+	// ColorAuto 根据NO_COLOR、FORCE_COLOR环境变量和输出是否为终端决定
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "2"
+	colorBold  = "1"
+	colorCyan  = "36"
+)
+
+// DefaultLevelColors 各级别默认的ANSI SGR参数
+var DefaultLevelColors = map[Level]string{
+	TraceLevel:  "90",
+	DebugLevel:  "35",
+	InfoLevel:   "32",
+	WarnLevel:   "33",
+	ErrorLevel:  "31",
+	DPanicLevel: "1;31",
+	PanicLevel:  "1;31",
+	FatalLevel:  "1;41;37",
+	AuditLevel:  "1;36",
+}
+
+// ConsoleFormatter 带颜色的TextFormatter，时间和调用位置变暗，级别按LevelColors着色，字段名和值高亮
+type ConsoleFormatter struct {
+	IgnoreBasicFields bool
+	ColorMode         ColorMode
+	// Output 不为空时ColorAuto只检测Output是否为终端；为空时检测entry实际写入的output，
+	// 使用appenders时所有使用该formatter的output都是终端才输出颜色
+	Output io.Writer
+	// LevelColors 覆盖DefaultLevelColors中对应级别的颜色
+	LevelColors map[Level]string
+	KeyColor    string
+	ValueColor  string
+
+	once sync.Once
+	//环境变量决定的结果，ColorAuto表示需要检测output
+	mode ColorMode
+	//最近一次检测的配置快照和结果，快照不变时output不变，不用每条日志都检测
+	terminal atomic.Pointer[colorCache]
+}
+
+type colorCache struct {
+	opt     *options
+	enabled bool
+}
+
+func (c *ConsoleFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	on := c.colorEnabled(e.opt)
+	if !c.IgnoreBasicFields {
+		buf = c.begin(buf, on, colorDim)
+		buf = e.Time.AppendFormat(buf, time.RFC3339)
+		buf = c.end(buf, on, colorDim)
+		buf = append(buf, ' ')
+
+		color := c.levelColor(e.Level)
+		buf = c.begin(buf, on, color)
+		name := e.Level.String()
+		buf = append(buf, name...)
+		for i := len(name); i < 5; i++ {
+			buf = append(buf, ' ')
+		}
+		buf = c.end(buf, on, color)
+
+		if e.File != "" {
+			buf = append(buf, ' ')
+			buf = c.begin(buf, on, colorDim)
+			buf = e.appendCallerFile(buf)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(e.Line), 10)
+			buf = c.end(buf, on, colorDim)
+		}
+		buf = append(buf, ' ')
+		if e.Name != "" {
+			buf = c.begin(buf, on, colorBold)
+			buf = append(buf, e.Name...)
+			buf = c.end(buf, on, colorBold)
+			buf = append(buf, ' ')
+		}
+	}
+	buf = e.AppendMessage(buf)
+	keyColor, valueColor := c.KeyColor, c.ValueColor
+	if keyColor == "" {
+		keyColor = colorCyan
+	}
+	if valueColor == "" {
+		valueColor = colorBold
+	}
+	for _, k := range e.Keys() {
+		buf = append(buf, ' ')
+		buf = c.begin(buf, on, keyColor)
+		buf = append(buf, k...)
+		buf = c.end(buf, on, keyColor)
+		buf = append(buf, '=')
+		buf = c.begin(buf, on, valueColor)
+		buf = appendValue(buf, e.Map[k])
+		buf = c.end(buf, on, valueColor)
+	}
+	buf = append(buf, '\n')
+	if len(e.Stack) > 0 {
+		buf = c.begin(buf, on, colorDim)
+		buf = appendStack(buf, e.Stack)
+		buf = c.end(buf, on, colorDim)
+	}
+	return buf, nil
+}
+
+func (c *ConsoleFormatter) begin(buf []byte, on bool, color string) []byte {
+	if !on || color == "" {
+		return buf
+	}
+	buf = append(buf, "\x1b["...)
+	buf = append(buf, color...)
+	return append(buf, 'm')
+}
+
+func (c *ConsoleFormatter) end(buf []byte, on bool, color string) []byte {
+	if !on || color == "" {
+		return buf
+	}
+	return append(buf, colorReset...)
+}
+
+func (c *ConsoleFormatter) levelColor(level Level) string {
+	if color, ok := c.LevelColors[level]; ok {
+		return color
+	}
+	if color, ok := DefaultLevelColors[level]; ok {
+		return color
+	}
+	//自定义级别使用注册时的颜色
+	if info := level.info(); info != nil {
+		return info.color
+	}
+	return ""
+}
+
+// colorEnabled 环境变量和Output只检测一次，Output为空时按opt中实际的output检测是否为终端
+func (c *ConsoleFormatter) colorEnabled(opt *options) bool {
+	c.once.Do(func() {
+		c.mode = c.envColorMode()
+		if c.mode == ColorAuto && c.Output != nil {
+			c.mode = ColorNever
+			if isTerminal(c.Output) {
+				c.mode = ColorAlways
+			}
+		}
+	})
+	if c.mode != ColorAuto {
+		return c.mode == ColorAlways
+	}
+	//不经过logger直接调用Format时没有配置快照
+	if opt == nil {
+		return isTerminal(os.Stderr)
+	}
+	if cache := c.terminal.Load(); cache != nil && cache.opt == opt {
+		return cache.enabled
+	}
+	enabled := c.outputsAreTerminals(opt)
+	c.terminal.Store(&colorCache{opt: opt, enabled: enabled})
+	return enabled
+}
+
+func (c *ConsoleFormatter) envColorMode() ColorMode {
+	if c.ColorMode != ColorAuto {
+		return c.ColorMode
+	}
+	//https://no-color.org
+	if os.Getenv("NO_COLOR") != "" {
+		return ColorNever
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		if force != "0" && force != "false" {
+			return ColorAlways
+		}
+		return ColorNever
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return ColorNever
+	}
+	return ColorAuto
+}
+
+// outputsAreTerminals 没有appender时检测output，否则检测所有使用c的appender的output
+func (c *ConsoleFormatter) outputsAreTerminals(opt *options) bool {
+	if len(opt.appenders) == 0 {
+		return isTerminal(opt.output)
+	}
+	for _, a := range opt.appenders {
+		if f, ok := a.Formatter.(*ConsoleFormatter); ok && f == c && !isTerminal(a.Output) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,69 @@
+package cuslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearColorEnv 清空影响ColorAuto的环境变量
+func clearColorEnv(t *testing.T) {
+	for _, k := range []string{"NO_COLOR", "FORCE_COLOR", "TERM"} {
+		t.Setenv(k, "")
+	}
+}
+
+// 写入文件时不输出颜色，与ConsoleFormatter.Output的默认值无关
+func TestConsoleColorFollowsOutput(t *testing.T) {
+	clearColorEnv(t)
+	f, err := os.Create(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l := New(WithOutput(f), WithFormatter(&ConsoleFormatter{}))
+	l.WithField("k", "v").Info("to file")
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "\x1b[") || !strings.Contains(string(data), "to file k=v") {
+		t.Errorf("file output should not be colored: %q", data)
+	}
+}
+
+func TestConsoleColorModes(t *testing.T) {
+	clearColorEnv(t)
+	buf := &lockedBuffer{}
+	New(WithOutput(buf), WithFormatter(&ConsoleFormatter{ColorMode: ColorAlways})).Warn("always")
+	New(WithOutput(buf), WithFormatter(&ConsoleFormatter{ColorMode: ColorNever})).Warn("never")
+	lines := buf.Lines()
+	if !strings.Contains(lines[0], "\x1b[33m") || strings.Contains(lines[1], "\x1b[") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	t.Setenv("FORCE_COLOR", "1")
+	buf = &lockedBuffer{}
+	New(WithOutput(buf), WithFormatter(&ConsoleFormatter{})).Info("forced")
+	if !strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("FORCE_COLOR should enable color: %q", buf.String())
+	}
+	t.Setenv("NO_COLOR", "1")
+	buf = &lockedBuffer{}
+	New(WithOutput(buf), WithFormatter(&ConsoleFormatter{})).Info("disabled")
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("NO_COLOR should disable color: %q", buf.String())
+	}
+}
+
+func TestConsoleColorAppenders(t *testing.T) {
+	clearColorEnv(t)
+	console := &ConsoleFormatter{}
+	buf, other := &lockedBuffer{}, &lockedBuffer{}
+	l := New(WithAppenders(NewAppender(buf, InfoLevel, console), NewAppender(other, InfoLevel, &TextFormatter{})))
+	l.Error("via appender")
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("appender output is not a terminal: %q", buf.String())
+	}
+}
@@ -1,42 +1,104 @@
 package cuslog
 
 import (
-	"encoding/json"
-	"fmt"
-	jsoniter "github.com/json-iterator/go"
 	"strconv"
 	"time"
 )
 
 type JsonFormatter struct {
 	IgnoreBasicFields bool
+	// SplitFunc 将调用函数拆分为package和func两个字段
+	SplitFunc bool
+	// StackFrames 调用栈输出为数组，默认输出为字符串
+	StackFrames bool
 }
 
-func (j *JsonFormatter) Format(e *Entry) error {
-	if !j.IgnoreBasicFields {
-		e.Map["level"] = LevelNameMapping[e.Level]
-		e.Map["time"] = e.Time.Format(time.RFC3339)
-		if e.File != "" {
-			e.Map["file"] = e.File + ":" + strconv.Itoa(e.Line)
-			e.Map["func"] = e.Func
+var jsonBasicKeys = map[string]bool{"time": true, "level": true, "logger": true, "file": true, "func": true, "package": true, "message": true, "stack": true}
+
+func (j *JsonFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	if j.IgnoreBasicFields {
+		return j.formatIgnoreBasicFields(buf, e)
+	}
+	buf = append(buf, `{"time":"`...)
+	buf = e.Time.AppendFormat(buf, time.RFC3339)
+	buf = append(buf, `","level":`...)
+	buf = appendJSONString(buf, e.Level.String())
+	if e.Name != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSONString(buf, e.Name)
+	}
+	if e.File != "" {
+		buf = append(buf, `,"file":"`...)
+		buf = appendJSONEscaped(buf, e.File)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(e.Line), 10)
+		if j.SplitFunc {
+			buf = append(buf, `","package":`...)
+			buf = appendJSONString(buf, e.Package)
+			buf = append(buf, `,"func":`...)
+			buf = appendJSONString(buf, e.Function)
+		} else {
+			buf = append(buf, `","func":`...)
+			buf = appendJSONString(buf, e.Func)
 		}
-		switch e.Format {
-		case FmtEmptySeparate:
-			e.Map["message"] = fmt.Sprint(e.Args...)
-		default:
-			e.Map["message"] = fmt.Sprintf(e.Format, e.Args...)
+	}
+	buf = append(buf, `,"message":`...)
+	buf = appendJSONString(buf, bytesToString(e.messageBytes()))
+	if len(e.Stack) > 0 {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONStack(buf, e.Stack, j.StackFrames)
+	}
+	var err error
+	for _, k := range e.Keys() {
+		buf = append(buf, ',')
+		//用户字段与基础字段重名时，改名为fields.key保留
+		if jsonBasicKeys[k] {
+			buf = appendJSONString(buf, "fields."+k)
+		} else {
+			buf = appendJSONString(buf, k)
 		}
-		return json.NewEncoder(e.Buffer).Encode(e.Map)
+		buf = append(buf, ':')
+		buf, err = appendJSONField(buf, e.Map[k], err)
+	}
+	return append(buf, "}\n"...), err
+}
+
+func (j *JsonFormatter) formatIgnoreBasicFields(buf []byte, e *Entry) ([]byte, error) {
+	var err error
+	if keys := e.Keys(); len(keys) > 0 {
+		buf = append(buf, '{')
+		for i, k := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, k)
+			buf = append(buf, ':')
+			buf, err = appendJSONField(buf, e.Map[k], err)
+		}
+		buf = append(buf, "}\n"...)
 	}
 	switch e.Format {
 	case FmtEmptySeparate:
 		for _, arg := range e.Args {
-			if err := jsoniter.NewEncoder(e.Buffer).Encode(arg); err != nil {
-				return err
-			}
+			buf, err = appendJSONField(buf, arg, err)
+			buf = append(buf, '\n')
 		}
 	default:
-		e.Buffer.WriteString(fmt.Sprintf(e.Format, e.Args))
+		buf = appendJSONString(buf, bytesToString(e.messageBytes()))
+		buf = append(buf, '\n')
+	}
+	return buf, err
+}
+
+// appendJSONField 无法序列化的值以字符串形式输出，保证结果仍是合法JSON，并返回第一个错误
+func appendJSONField(buf []byte, v interface{}, err error) ([]byte, error) {
+	out, encErr := appendJSONValue(buf, v)
+	if encErr == nil {
+		return out, err
+	}
+	buf = appendJSONString(buf, string(appendValue(nil, v)))
+	if err == nil {
+		err = encErr
 	}
-	return nil
+	return buf, err
 }
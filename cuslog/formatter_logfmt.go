@@ -0,0 +1,102 @@
+package cuslog
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter 输出 time=... level=info caller=main.go:25 msg="..." key=value 格式
+type LogfmtFormatter struct {
+	IgnoreBasicFields bool
+}
+
+var logfmtBasicKeys = map[string]bool{"time": true, "level": true, "logger": true, "caller": true, "msg": true, "stack": true}
+
+func (l *LogfmtFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	if !l.IgnoreBasicFields {
+		buf = append(buf, "time="...)
+		buf = e.Time.AppendFormat(buf, time.RFC3339)
+		buf = append(buf, " level="...)
+		buf = appendLower(buf, e.Level.String())
+		if e.Name != "" {
+			buf = append(buf, " logger="...)
+			start := len(buf)
+			buf = append(buf, e.Name...)
+			buf = quoteLogfmtValue(buf, start)
+		}
+		if e.File != "" {
+			buf = append(buf, " caller="...)
+			start := len(buf)
+			buf = e.appendCallerFile(buf)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(e.Line), 10)
+			buf = quoteLogfmtValue(buf, start)
+		}
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, "msg="...)
+	start := len(buf)
+	buf = e.AppendMessage(buf)
+	buf = quoteLogfmtValue(buf, start)
+	for _, k := range e.Keys() {
+		buf = append(buf, ' ')
+		//与基础字段重名时加前缀，避免解析时覆盖
+		if logfmtBasicKeys[k] {
+			buf = append(buf, "fields."...)
+		}
+		buf = appendLogfmtPair(buf, k, e.Map[k])
+	}
+	if len(e.Stack) > 0 {
+		buf = append(buf, " stack="...)
+		start := len(buf)
+		buf = appendStack(buf, e.Stack)
+		buf = quoteLogfmtValue(buf, start)
+	}
+	return append(buf, '\n'), nil
+}
+
+func appendLogfmtPair(buf []byte, key string, value interface{}) []byte {
+	buf = appendLogfmtKey(buf, key)
+	buf = append(buf, '=')
+	start := len(buf)
+	buf = appendValue(buf, value)
+	return quoteLogfmtValue(buf, start)
+}
+
+// appendLogfmtKey key中不允许出现空格、引号、=和控制字符，替换为_
+func appendLogfmtKey(buf []byte, key string) []byte {
+	if key == "" {
+		return append(buf, '_')
+	}
+	for _, r := range key {
+		if logfmtNeedsQuote(r) {
+			r = '_'
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
+}
+
+// quoteLogfmtValue buf[start:]为刚追加的值，需要时替换为带引号的形式
+func quoteLogfmtValue(buf []byte, start int) []byte {
+	value := buf[start:]
+	if len(value) > 0 {
+		quote := false
+		for _, r := range bytesToString(value) {
+			if logfmtNeedsQuote(r) || r == '\\' {
+				quote = true
+				break
+			}
+		}
+		if !quote {
+			return buf
+		}
+	}
+	//需要转义的情况较少，复制一份后重新追加
+	return strconv.AppendQuote(buf[:start], string(value))
+}
+
+func logfmtNeedsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f
+}
@@ -0,0 +1,54 @@
+package cuslog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLogfmtQuoting(t *testing.T) {
+	cases := []struct {
+		msg    string
+		fields Fields
+		want   string
+	}{
+		{"plain", nil, `msg=plain`},
+		{"", nil, `msg=""`},
+		{"two words", nil, `msg="two words"`},
+		{`say "hi"`, nil, `msg="say \"hi\""`},
+		{"a=b", nil, `msg="a=b"`},
+		{"line\nnext\ttab", nil, `msg="line\nnext\ttab"`},
+		{`C:\path`, nil, `msg="C:\\path"`},
+		{"bell\x07", nil, `msg="bell\a"`},
+		{"ok", Fields{"path": "/a b"}, `msg=ok path="/a b"`},
+		{"ok", Fields{"query": "x=1"}, `msg=ok query="x=1"`},
+		{"ok", Fields{"empty": ""}, `msg=ok empty=""`},
+		{"ok", Fields{"n": 42}, `msg=ok n=42`},
+		{"ok", Fields{"bad key=": 1}, `msg=ok bad_key_=1`},
+		{"ok", Fields{"error": errors.New("not found")}, `msg=ok error="not found"`},
+	}
+	for _, c := range cases {
+		buf := &lockedBuffer{}
+		l := New(WithOutput(buf), WithFormatter(&LogfmtFormatter{IgnoreBasicFields: true}))
+		l.WithFields(c.fields).Info(c.msg)
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != c.want {
+			t.Errorf("Info(%q) with %v = %s, want %s", c.msg, c.fields, got, c.want)
+		}
+	}
+}
+
+func TestLogfmtBasicFields(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithFormatter(&LogfmtFormatter{}), WithName("api server"))
+	l.WithFields(Fields{"msg": "shadow", "user": "alice"}).Warn("login failed")
+	line := buf.String()
+	for _, want := range []string{"time=", " level=warn ", ` logger="api server" `, " caller=formatter_logfmt_test.go:",
+		` msg="login failed" `, " fields.msg=shadow ", " user=alice\n"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q does not contain %q", line, want)
+		}
+	}
+	if !strings.HasPrefix(line, "time=") {
+		t.Errorf("time should come first: %q", line)
+	}
+}
@@ -0,0 +1,274 @@
+package cuslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultPatternLayout PatternFormatter未设置Layout时使用
+const DefaultPatternLayout = "%d [%-5p] %F{short}:%L %m %fields%n"
+
+// PatternFormatter 按Layout输出，Layout只在第一次使用时编译一次
+//
+// 支持的占位符：
+//
+//	%d{layout}{tz} 时间，layout为go时间格式，默认RFC3339，tz为UTC、Local或IANA时区名
+//	%p{lower|short} 级别，short为三个字母的缩写
+//	%c{short}       logger名称，short只保留最后一段
+//	%F{short}       文件路径，short只保留文件名
+//	%L              行号
+//	%M{pkg|name}    函数名，pkg为完整包路径，name为不含包名的函数名
+//	%m              日志内容
+//	%X{key}         单个字段
+//	%fields         所有字段，格式为key=value
+//	%n              换行
+//	%%              百分号
+//
+// 占位符可以指定宽度，如%-5p左对齐、%5p右对齐
+type PatternFormatter struct {
+	Layout string
+
+	once    sync.Once
+	program []patternOp
+	err     error
+}
+
+type patternOp struct {
+	verb    string
+	literal string
+	arg     string
+	loc     *time.Location
+	width   int
+	left    bool
+}
+
+// NewPatternFormatter 立即编译layout，layout有误时返回错误
+func NewPatternFormatter(layout string) (*PatternFormatter, error) {
+	p := &PatternFormatter{Layout: layout}
+	p.compile()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p, nil
+}
+
+func (p *PatternFormatter) compile() {
+	p.once.Do(func() {
+		layout := p.Layout
+		if layout == "" {
+			layout = DefaultPatternLayout
+		}
+		p.program, p.err = compilePattern(layout)
+	})
+}
+
+func (p *PatternFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	p.compile()
+	if p.err != nil {
+		return buf, p.err
+	}
+	for i := range p.program {
+		op := &p.program[i]
+		if op.verb == "" {
+			buf = append(buf, op.literal...)
+			continue
+		}
+		start := len(buf)
+		buf = op.append(buf, e)
+		pad := op.width - utf8.RuneCount(buf[start:])
+		if pad <= 0 {
+			continue
+		}
+		end := len(buf)
+		for j := 0; j < pad; j++ {
+			buf = append(buf, ' ')
+		}
+		if !op.left {
+			//右对齐时将内容后移，空格放在前面
+			copy(buf[start+pad:], buf[start:end])
+			for j := start; j < start+pad; j++ {
+				buf[j] = ' '
+			}
+		}
+	}
+	return buf, nil
+}
+
+func (op *patternOp) append(buf []byte, e *Entry) []byte {
+	switch op.verb {
+	case "d":
+		t := e.Time
+		if op.loc != nil {
+			t = t.In(op.loc)
+		}
+		return t.AppendFormat(buf, op.arg)
+	case "p":
+		name := e.Level.String()
+		switch op.arg {
+		case "lower":
+			return appendLower(buf, name)
+		case "short":
+			return append(buf, e.Level.shortName()...)
+		}
+		return append(buf, name...)
+	case "c":
+		if op.arg == "short" {
+			return append(buf, e.Name[strings.LastIndex(e.Name, ".")+1:]...)
+		}
+		return append(buf, e.Name...)
+	case "F":
+		if op.arg == "short" {
+			return appendShortFile(buf, e.File)
+		}
+		return append(buf, e.File...)
+	case "L":
+		if e.File == "" {
+			return buf
+		}
+		return strconv.AppendInt(buf, int64(e.Line), 10)
+	case "M":
+		switch op.arg {
+		case "pkg":
+			return append(buf, e.Package...)
+		case "name":
+			return append(buf, e.Function...)
+		}
+		return append(buf, e.Func...)
+	case "m":
+		return e.AppendMessage(buf)
+	case "X":
+		if v, ok := e.Map[op.arg]; ok {
+			start := len(buf)
+			return quoteLogfmtValue(appendValue(buf, v), start)
+		}
+		return buf
+	case "fields":
+		for i, k := range e.Keys() {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = appendLogfmtPair(buf, k, e.Map[k])
+		}
+	}
+	return buf
+}
+
+func compilePattern(layout string) ([]patternOp, error) {
+	var program []patternOp
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			program = append(program, patternOp{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			literal.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if i >= len(layout) {
+			return nil, fmt.Errorf("cuslog: pattern %q ends with %%", layout)
+		}
+		switch layout[i] {
+		case '%':
+			literal.WriteByte('%')
+			continue
+		case 'n':
+			literal.WriteByte('\n')
+			continue
+		}
+
+		op := patternOp{}
+		if layout[i] == '-' {
+			op.left = true
+			i++
+		}
+		start := i
+		for i < len(layout) && layout[i] >= '0' && layout[i] <= '9' {
+			i++
+		}
+		if i > start {
+			op.width, _ = strconv.Atoi(layout[start:i])
+		}
+		if i >= len(layout) {
+			return nil, fmt.Errorf("cuslog: pattern %q ends without verb", layout)
+		}
+		if strings.HasPrefix(layout[i:], "fields") {
+			op.verb = "fields"
+			i += len("fields") - 1
+		} else {
+			op.verb = layout[i : i+1]
+		}
+
+		var args []string
+		for i+1 < len(layout) && layout[i+1] == '{' {
+			end := strings.IndexByte(layout[i+1:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("cuslog: pattern %q has unclosed {", layout)
+			}
+			args = append(args, layout[i+2:i+1+end])
+			i += end + 1
+		}
+		if err := op.parseArgs(args); err != nil {
+			return nil, err
+		}
+		flush()
+		program = append(program, op)
+	}
+	flush()
+	return program, nil
+}
+
+func (op *patternOp) parseArgs(args []string) error {
+	switch op.verb {
+	case "d":
+		op.arg = time.RFC3339
+		if len(args) > 0 && args[0] != "" {
+			op.arg = args[0]
+		}
+		if len(args) > 1 {
+			switch args[1] {
+			case "UTC":
+				op.loc = time.UTC
+			case "Local":
+				op.loc = time.Local
+			default:
+				loc, err := time.LoadLocation(args[1])
+				if err != nil {
+					return fmt.Errorf("cuslog: invalid time zone %q: %v", args[1], err)
+				}
+				op.loc = loc
+			}
+		}
+		return nil
+	case "p":
+		if len(args) > 0 && args[0] != "lower" && args[0] != "short" {
+			return fmt.Errorf("cuslog: invalid level option %q", args[0])
+		}
+	case "M":
+		if len(args) > 0 && args[0] != "pkg" && args[0] != "name" {
+			return fmt.Errorf("cuslog: invalid %%M option %q", args[0])
+		}
+	case "c", "F":
+		if len(args) > 0 && args[0] != "short" {
+			return fmt.Errorf("cuslog: invalid %%%s option %q", op.verb, args[0])
+		}
+	case "X":
+		if len(args) == 0 || args[0] == "" {
+			return fmt.Errorf("cuslog: %%X requires a field name")
+		}
+	case "L", "m", "fields":
+	default:
+		return fmt.Errorf("cuslog: unknown pattern verb %%%s", op.verb)
+	}
+	if len(args) > 0 {
+		op.arg = args[0]
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cuslog
+
+import (
+	"io"
+	"testing"
+)
+
+func benchmarkFormatter(b *testing.B, f Formatter) {
+	b.Run("Info", func(b *testing.B) {
+		l := New(WithOutput(io.Discard), WithFormatter(f))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled")
+		}
+	})
+	b.Run("Infof", func(b *testing.B) {
+		l := New(WithOutput(io.Discard), WithFormatter(f))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Infof("request handled in %dms", 42)
+		}
+	})
+	b.Run("WithFields", func(b *testing.B) {
+		l := New(WithOutput(io.Discard), WithFormatter(f)).WithFields(Fields{
+			"user": "alice", "status": 200, "latency": 0.25,
+		})
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled")
+		}
+	})
+}
+
+func BenchmarkText(b *testing.B) {
+	benchmarkFormatter(b, &TextFormatter{})
+}
+
+func BenchmarkJSON(b *testing.B) {
+	benchmarkFormatter(b, &JsonFormatter{})
+}
+
+func BenchmarkLogfmt(b *testing.B) {
+	benchmarkFormatter(b, &LogfmtFormatter{})
+}
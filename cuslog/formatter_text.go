@@ -1,8 +1,7 @@
 package cuslog
 
 import (
-	"fmt"
-	"strings"
+	"strconv"
 	"time"
 )
 
@@ -10,22 +9,31 @@ type TextFormatter struct {
 	IgnoreBasicFields bool
 }
 
-func (t *TextFormatter) Format(e *Entry) error {
+func (t *TextFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
 	if !t.IgnoreBasicFields {
-		e.Buffer.WriteString(fmt.Sprintf("%s %s->", e.Time.Format(time.RFC3339), LevelNameMapping[e.Level]))
+		buf = e.Time.AppendFormat(buf, time.RFC3339)
+		buf = append(buf, ' ')
+		buf = append(buf, e.Level.String()...)
+		buf = append(buf, "->"...)
 		if e.File != "" {
-			short := e.File[strings.LastIndex(e.File, "/")+1:]
-			e.Buffer.WriteString(fmt.Sprintf("%s:%d", short, e.Line))
+			buf = e.appendCallerFile(buf)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(e.Line), 10)
+		}
+		buf = append(buf, ' ')
+		if e.Name != "" {
+			buf = append(buf, '[')
+			buf = append(buf, e.Name...)
+			buf = append(buf, "] "...)
 		}
-		e.Buffer.WriteString(" ")
 	}
-	switch e.Format {
-	case FmtEmptySeparate:
-		e.Buffer.WriteString(fmt.Sprint(e.Args...))
-	default:
-		e.Buffer.WriteString(fmt.Sprintf(e.Format, e.Args...))
+	buf = e.AppendMessage(buf)
+	for _, k := range e.Keys() {
+		buf = append(buf, ' ')
+		buf = append(buf, k...)
+		buf = append(buf, '=')
+		buf = appendValue(buf, e.Map[k])
 	}
-	e.Buffer.WriteString("\n")
-
-	return nil
+	buf = append(buf, '\n')
+	return appendStack(buf, e.Stack), nil
 }
@@ -0,0 +1,326 @@
+package cuslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GelfFormatter 输出Graylog的GELF 1.1格式
+//
+// 日志内容的第一行作为short_message，多行时完整内容作为full_message；
+// 字段名加_前缀，调用位置输出为_file、_line、_func，与其重名或名为id的字段改名为_fields.key
+type GelfFormatter struct {
+	// Host 默认os.Hostname()
+	Host string
+
+	once sync.Once
+}
+
+var gelfBasicKeys = map[string]bool{"id": true, "file": true, "line": true, "func": true, "logger": true}
+
+func (g *GelfFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	g.once.Do(func() {
+		if g.Host == "" {
+			g.Host, _ = os.Hostname()
+		}
+	})
+	buf = append(buf, `{"version":"1.1","host":`...)
+	buf = appendJSONString(buf, g.Host)
+	msg := bytesToString(e.messageBytes())
+	short, multiline := msg, false
+	if i := bytes.IndexByte(e.messageBytes(), '\n'); i >= 0 {
+		short, multiline = msg[:i], true
+	}
+	if short == "" {
+		//short_message不能为空
+		short = "-"
+	}
+	buf = append(buf, `,"short_message":`...)
+	buf = appendJSONString(buf, short)
+	if multiline {
+		buf = append(buf, `,"full_message":`...)
+		buf = appendJSONString(buf, msg)
+	}
+	//秒为单位，保留微秒
+	buf = append(buf, `,"timestamp":`...)
+	usec := e.Time.UnixMicro()
+	buf = strconv.AppendInt(buf, usec/1e6, 10)
+	buf = append(buf, '.')
+	frac := strconv.AppendInt(nil, usec%1e6+1e6, 10)
+	buf = append(buf, frac[1:]...)
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, int64(syslogSeverity(e.Level)), 10)
+	if e.File != "" {
+		buf = append(buf, `,"_file":`...)
+		buf = appendJSONString(buf, e.File)
+		buf = append(buf, `,"_line":`...)
+		buf = strconv.AppendInt(buf, int64(e.Line), 10)
+		buf = append(buf, `,"_func":`...)
+		buf = appendJSONString(buf, e.Func)
+	}
+	if e.Name != "" {
+		buf = append(buf, `,"_logger":`...)
+		buf = appendJSONString(buf, e.Name)
+	}
+	for _, k := range e.Keys() {
+		buf = append(buf, `,"_`...)
+		if gelfBasicKeys[k] {
+			buf = append(buf, "fields."...)
+		}
+		buf = appendGelfKey(buf, k)
+		buf = append(buf, `":`...)
+		buf = appendGelfValue(buf, e.Map[k])
+	}
+	return append(buf, "}\n"...), nil
+}
+
+// appendGelfKey 字段名只允许字母、数字、_、.和-，其他字符替换为_
+func appendGelfKey(buf []byte, k string) []byte {
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '-') {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// appendGelfValue GELF的附加字段只能是字符串或数字，其他类型转为字符串
+func appendGelfValue(buf []byte, v interface{}) []byte {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		if out, err := appendJSONValue(buf, v); err == nil {
+			return out
+		}
+	case string:
+		return appendJSONString(buf, v.(string))
+	}
+	return appendJSONString(buf, bytesToString(appendValue(nil, v)))
+}
+
+// GelfCompression UDP传输时的压缩方式
+type GelfCompression uint8
+
+const (
+	GelfCompressGzip GelfCompression = iota
+	GelfCompressZlib
+	GelfCompressNone
+)
+
+const (
+	// DefaultGelfChunkSize 适用于跨公网传输，局域网可以设置为8154
+	DefaultGelfChunkSize = 1420
+	gelfChunkHeaderSize  = 12
+	gelfMaxChunks        = 128
+)
+
+// ErrGelfTooLarge 消息超过128个分块时无法通过UDP发送
+var ErrGelfTooLarge = errors.New("cuslog: gelf message exceeds 128 chunks")
+
+type gelfOptions struct {
+	compression GelfCompression
+	chunkSize   int
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+type GelfOption func(options2 *gelfOptions)
+
+// WithGelfCompression 只对UDP生效，Graylog的TCP输入不支持压缩
+func WithGelfCompression(compression GelfCompression) GelfOption {
+	return GelfOption(func(options2 *gelfOptions) {
+		options2.compression = compression
+	})
+}
+
+// WithGelfChunkSize UDP单个数据报的最大字节数，超过时分块发送
+func WithGelfChunkSize(size int) GelfOption {
+	return GelfOption(func(options2 *gelfOptions) {
+		options2.chunkSize = size
+	})
+}
+
+// WithGelfTLSConfig network为tls时使用的配置
+func WithGelfTLSConfig(config *tls.Config) GelfOption {
+	return GelfOption(func(options2 *gelfOptions) {
+		options2.tlsConfig = config
+	})
+}
+
+func WithGelfDialTimeout(timeout time.Duration) GelfOption {
+	return GelfOption(func(options2 *gelfOptions) {
+		options2.dialTimeout = timeout
+	})
+}
+
+// WithGelfBackoff 重连失败后等待min再重试，每次失败翻倍，最长max
+func WithGelfBackoff(min, max time.Duration) GelfOption {
+	return GelfOption(func(options2 *gelfOptions) {
+		options2.minBackoff, options2.maxBackoff = min, max
+	})
+}
+
+// GelfWriter 将GelfFormatter的输出发送到Graylog，可作为WithOutput的参数
+//
+// network为udp时按配置压缩，超过分块大小时按GELF分块发送；network为tcp或tls时以\0分隔消息；
+// 第一次写入时才连接，连接失败时按退避时间重试
+type GelfWriter struct {
+	network string
+	addr    string
+	opt     gelfOptions
+	conn    *netConn
+}
+
+func NewGelfWriter(network, addr string, opts ...GelfOption) (*GelfWriter, error) {
+	o := gelfOptions{chunkSize: DefaultGelfChunkSize, dialTimeout: 5 * time.Second,
+		minBackoff: 100 * time.Millisecond, maxBackoff: 30 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.chunkSize <= gelfChunkHeaderSize {
+		o.chunkSize = DefaultGelfChunkSize
+	}
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls":
+	default:
+		return nil, fmt.Errorf("cuslog: unsupported gelf network %q", network)
+	}
+	w := &GelfWriter{network: network, addr: addr, opt: o}
+	w.conn = newNetConn(w.dial, o.minBackoff, o.maxBackoff)
+	return w, nil
+}
+
+func (w *GelfWriter) Write(p []byte) (int, error) {
+	msg := p
+	for len(msg) > 0 && (msg[len(msg)-1] == '\n' || msg[len(msg)-1] == '\r') {
+		msg = msg[:len(msg)-1]
+	}
+	var err error
+	if w.network == "udp" || w.network == "udp4" || w.network == "udp6" {
+		err = w.writeUDP(msg)
+	} else {
+		err = w.conn.write(func(conn net.Conn) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(w.opt.dialTimeout))
+			frame := make([]byte, 0, len(msg)+1)
+			frame = append(append(frame, msg...), 0)
+			_, err := conn.Write(frame)
+			return err
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *GelfWriter) Close() error {
+	return w.conn.close()
+}
+
+func (w *GelfWriter) dial() (net.Conn, error) {
+	if w.network == "tls" {
+		dialer := &net.Dialer{Timeout: w.opt.dialTimeout}
+		return tls.DialWithDialer(dialer, "tcp", w.addr, w.opt.tlsConfig)
+	}
+	return net.DialTimeout(w.network, w.addr, w.opt.dialTimeout)
+}
+
+type gelfCompressor struct {
+	buf  bytes.Buffer
+	gzip *gzip.Writer
+	zlib *zlib.Writer
+}
+
+var gelfCompressorPool = sync.Pool{New: func() interface{} {
+	return &gelfCompressor{}
+}}
+
+func (w *GelfWriter) writeUDP(msg []byte) error {
+	c := gelfCompressorPool.Get().(*gelfCompressor)
+	defer gelfCompressorPool.Put(c)
+	c.buf.Reset()
+	switch w.opt.compression {
+	case GelfCompressGzip:
+		if c.gzip == nil {
+			c.gzip = gzip.NewWriter(&c.buf)
+		} else {
+			c.gzip.Reset(&c.buf)
+		}
+		c.gzip.Write(msg)
+		c.gzip.Close()
+		msg = c.buf.Bytes()
+	case GelfCompressZlib:
+		if c.zlib == nil {
+			c.zlib = zlib.NewWriter(&c.buf)
+		} else {
+			c.zlib.Reset(&c.buf)
+		}
+		c.zlib.Write(msg)
+		c.zlib.Close()
+		msg = c.buf.Bytes()
+	}
+
+	if len(msg) <= w.opt.chunkSize {
+		return w.conn.write(func(conn net.Conn) error {
+			_, err := conn.Write(msg)
+			return err
+		})
+	}
+	dataSize := w.opt.chunkSize - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return ErrGelfTooLarge
+	}
+	//分块格式：0x1e 0x0f，8字节消息ID，序号，总数，数据
+	chunk := make([]byte, gelfChunkHeaderSize, w.opt.chunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	binary.BigEndian.PutUint64(chunk[2:10], gelfMessageID())
+	chunk[11] = byte(count)
+	return w.conn.write(func(conn net.Conn) error {
+		for i := 0; i < count; i++ {
+			end := (i + 1) * dataSize
+			if end > len(msg) {
+				end = len(msg)
+			}
+			chunk[10] = byte(i)
+			if _, err := conn.Write(append(chunk[:gelfChunkHeaderSize], msg[i*dataSize:end]...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var (
+	gelfIDBase uint64
+	gelfIDSeq  uint64
+)
+
+func init() {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err == nil {
+		gelfIDBase = binary.BigEndian.Uint64(b[:])
+	} else {
+		gelfIDBase = uint64(time.Now().UnixNano())
+	}
+}
+
+// gelfMessageID 随机起点加自增序号，同一进程内不会重复
+func gelfMessageID() uint64 {
+	return gelfIDBase + atomic.AddUint64(&gelfIDSeq, 1)
+}
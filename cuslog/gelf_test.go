@@ -0,0 +1,177 @@
+package cuslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGelfLogger(t *testing.T, network, addr string, opts ...GelfOption) *logger {
+	t.Helper()
+	w, err := NewGelfWriter(network, addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return New(WithOutput(w), WithFormatter(&GelfFormatter{Host: "host"}))
+}
+
+func decodeGelf(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid gelf message %q: %v", data, err)
+	}
+	return m
+}
+
+// readGelfUDP 读取一条UDP消息，按需合并分块并解压
+func readGelfUDP(t *testing.T, pc net.PacketConn) []byte {
+	t.Helper()
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	var chunks [][]byte
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := append([]byte(nil), buf[:n]...)
+		if len(p) < 2 || p[0] != 0x1e || p[1] != 0x0f {
+			return gunzipIfNeeded(t, p)
+		}
+		if chunks == nil {
+			chunks = make([][]byte, p[11])
+		}
+		chunks[p[10]] = p[gelfChunkHeaderSize:]
+		complete := true
+		for _, c := range chunks {
+			complete = complete && c != nil
+		}
+		if complete {
+			return gunzipIfNeeded(t, bytes.Join(chunks, nil))
+		}
+	}
+}
+
+func gunzipIfNeeded(t *testing.T, p []byte) []byte {
+	t.Helper()
+	if len(p) < 2 || p[0] != 0x1f || p[1] != 0x8b {
+		return p
+	}
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestGelfFormatter(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithFormatter(&GelfFormatter{Host: "host"}))
+	l.WithFields(Fields{"id": 7, "user": "alice"}).Error("first line\nsecond line")
+	m := decodeGelf(t, []byte(buf.String()))
+	if m["version"] != "1.1" || m["host"] != "host" || m["short_message"] != "first line" ||
+		m["full_message"] != "first line\nsecond line" || m["level"] != float64(severityErr) {
+		t.Errorf("unexpected basic fields: %v", m)
+	}
+	file, _ := m["_file"].(string)
+	if m["_fields.id"] != float64(7) || m["_user"] != "alice" || !strings.HasSuffix(file, "gelf_test.go") {
+		t.Errorf("unexpected additional fields: %v", m)
+	}
+}
+
+func TestGelfWriterUDPChunked(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	l := newGelfLogger(t, "udp", pc.LocalAddr().String(), WithGelfCompression(GelfCompressNone), WithGelfChunkSize(200))
+	long := strings.Repeat("0123456789", 100)
+	l.Info(long)
+	if m := decodeGelf(t, readGelfUDP(t, pc)); m["short_message"] != long {
+		t.Errorf("unexpected short_message: %v", m["short_message"])
+	}
+}
+
+func TestGelfWriterUDPGzip(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	l := newGelfLogger(t, "udp", pc.LocalAddr().String())
+	l.Info("compressed")
+	if m := decodeGelf(t, readGelfUDP(t, pc)); m["short_message"] != "compressed" {
+		t.Errorf("unexpected short_message: %v", m["short_message"])
+	}
+}
+
+func TestGelfWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l := newGelfLogger(t, "tcp", ln.Addr().String())
+	l.Info("first")
+	l.Info("second")
+
+	r := acceptOne(t, ln)
+	for _, want := range []string{"first", "second"} {
+		frame, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := decodeGelf(t, frame[:len(frame)-1]); m["short_message"] != want {
+			t.Errorf("got %v, want %s", m["short_message"], want)
+		}
+	}
+}
+
+func TestGelfWriterStartsWhileServerDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w, err := NewGelfWriter("tcp", addr, WithGelfBackoff(10*time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("writer should be created while the server is down: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte(`{"short_message":"lost"}`)); err == nil {
+		t.Fatal("write should fail while the server is down")
+	}
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("port reused: %v", err)
+	}
+	defer ln.Close()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := w.Write([]byte(`{"short_message":"delivered"}`)); err != nil {
+		t.Fatalf("write after server started: %v", err)
+	}
+	frame, err := acceptOne(t, ln).ReadString(0)
+	if err != nil || frame != "{\"short_message\":\"delivered\"}\x00" {
+		t.Errorf("got %q, %v", frame, err)
+	}
+}
+
+func TestGelfWriterUnsupportedNetwork(t *testing.T) {
+	if _, err := NewGelfWriter("unix", "/dev/log"); err == nil {
+		t.Error("unsupported network should be rejected")
+	}
+}
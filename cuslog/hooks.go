@@ -0,0 +1,57 @@
+package cuslog
+
+import (
+	"fmt"
+	"os"
+)
+
+// Hook 在entry格式化之后、写入output之前触发，Levels返回关心的级别
+type Hook interface {
+	Levels() []Level
+	Fire(*Entry) error
+}
+
+// HookErrorHandler 处理Hook.Fire返回的错误
+type HookErrorHandler func(hook Hook, e *Entry, err error)
+
+// LevelHooks 按级别索引的hook集合
+type LevelHooks map[Level][]Hook
+
+// add 写时复制，正在触发的hook列表不受影响
+func (h LevelHooks) add(hook Hook) LevelHooks {
+	hooks := make(LevelHooks, len(h))
+	for level, hs := range h {
+		hooks[level] = hs
+	}
+	for _, level := range hook.Levels() {
+		hs := make([]Hook, len(hooks[level]), len(hooks[level])+1)
+		copy(hs, hooks[level])
+		hooks[level] = append(hs, hook)
+	}
+	return hooks
+}
+
+func defaultHookErrorHandler(hook Hook, e *Entry, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "cuslog: failed to fire hook %T at level %s: %v\n", hook, e.Level.String(), err)
+}
+
+// AddHook 为logger及其子logger添加hook
+func (l *logger) AddHook(hook Hook) {
+	l.SetOptions(WithHooks(hook))
+}
+
+func AddHook(hook Hook) {
+	std.AddHook(hook)
+}
+
+func (e *Entry) fireHooks() {
+	hooks, handler := e.opt.hooks[e.Level], e.opt.hookErrorHandler
+	if handler == nil {
+		handler = defaultHookErrorHandler
+	}
+	for _, hook := range hooks {
+		if err := hook.Fire(e); err != nil {
+			handler(hook, e, err)
+		}
+	}
+}
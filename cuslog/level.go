@@ -0,0 +1,218 @@
+package cuslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level 日志级别，数值越大越严重，内置级别之间留有间隔用于注册自定义级别
+type Level uint8
+
+const (
+	TraceLevel Level = 10
+	DebugLevel Level = 20
+	InfoLevel  Level = 30
+	// AuditLevel 审计日志，不受logger、appender级别和按名称配置的级别过滤
+	//
+	// 审计日志不表示严重程度，数值位于INFO和WARN之间，与syslog的notice对应，
+	// 不会达到ERROR等阈值而记录调用栈或触发flight recorder
+	AuditLevel  Level = 35
+	WarnLevel   Level = 40
+	ErrorLevel  Level = 50
+	DPanicLevel Level = 60
+	PanicLevel  Level = 70
+	FatalLevel  Level = 80
+)
+
+// LevelNameMapping 内置级别的名称
+//
+// Deprecated: 不包含自定义级别，使用Level.String
+var LevelNameMapping = map[Level]string{
+	TraceLevel:  "TRACE",
+	DebugLevel:  "DEBUG",
+	InfoLevel:   "INFO",
+	AuditLevel:  "AUDIT",
+	WarnLevel:   "WARN",
+	ErrorLevel:  "ERROR",
+	DPanicLevel: "DPANIC",
+	PanicLevel:  "PANIC",
+	FatalLevel:  "FATAL",
+}
+
+type levelInfo struct {
+	name  string
+	short string
+	color string
+	//syslog severity，同时用于GELF
+	syslog     int
+	unfiltered bool
+}
+
+type levelTable [256]*levelInfo
+
+var (
+	//只串行化注册，读取时无锁
+	levelMu sync.Mutex
+	levels  = func() *atomic.Pointer[levelTable] {
+		t := &levelTable{
+			TraceLevel:  {name: "TRACE", short: "TRC", syslog: severityDebug},
+			DebugLevel:  {name: "DEBUG", short: "DBG", syslog: severityDebug},
+			InfoLevel:   {name: "INFO", short: "INF", syslog: severityInfo},
+			AuditLevel:  {name: "AUDIT", short: "AUD", syslog: severityNotice, unfiltered: true},
+			WarnLevel:   {name: "WARN", short: "WRN", syslog: severityWarning},
+			ErrorLevel:  {name: "ERROR", short: "ERR", syslog: severityErr},
+			DPanicLevel: {name: "DPANIC", short: "DPN", syslog: severityCrit},
+			PanicLevel:  {name: "PANIC", short: "PNC", syslog: severityAlert},
+			FatalLevel:  {name: "FATAL", short: "FTL", syslog: severityEmerg},
+		}
+		p := &atomic.Pointer[levelTable]{}
+		p.Store(t)
+		return p
+	}()
+)
+
+type LevelOption func(info *levelInfo)
+
+// WithLevelShortName PatternFormatter的%p{short}使用，默认为名称的前三个字母
+func WithLevelShortName(short string) LevelOption {
+	return LevelOption(func(info *levelInfo) {
+		info.short = short
+	})
+}
+
+// WithLevelColor ConsoleFormatter使用的ANSI SGR参数，如"1;35"
+func WithLevelColor(color string) LevelOption {
+	return LevelOption(func(info *levelInfo) {
+		info.color = color
+	})
+}
+
+// WithLevelSyslogSeverity syslog和GELF使用的severity，默认与不高于该级别的最近内置级别相同
+func WithLevelSyslogSeverity(severity int) LevelOption {
+	return LevelOption(func(info *levelInfo) {
+		info.syslog = severity
+	})
+}
+
+// WithLevelUnfiltered 该级别的日志不会被级别过滤
+func WithLevelUnfiltered() LevelOption {
+	return LevelOption(func(info *levelInfo) {
+		info.unfiltered = true
+	})
+}
+
+// RegisterLevel 注册自定义级别，级别的数值或名称（不区分大小写）已存在时返回错误
+//
+// 注册之前创建的Hook不会收到该级别，需要在Levels中返回它
+func RegisterLevel(level Level, name string, opts ...LevelOption) error {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" || strings.ContainsAny(name, " \t=,") {
+		return fmt.Errorf("cuslog: invalid level name %q", name)
+	}
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	old := levels.Load()
+	if old[level] != nil {
+		return fmt.Errorf("cuslog: level %d already registered as %s", level, old[level].name)
+	}
+	for _, info := range old {
+		if info != nil && info.name == name {
+			return fmt.Errorf("cuslog: level name %s already registered", name)
+		}
+	}
+	info := &levelInfo{name: name, short: name, syslog: severityDebug}
+	if len(info.short) > 3 {
+		info.short = info.short[:3]
+	}
+	for l := int(level); l >= 0; l-- {
+		if builtin := old[l]; builtin != nil {
+			info.syslog = builtin.syslog
+			break
+		}
+	}
+	for _, opt := range opts {
+		opt(info)
+	}
+	t := *old
+	t[level] = info
+	levels.Store(&t)
+	return nil
+}
+
+// AllLevels 按从低到高的顺序返回所有已注册的级别
+func AllLevels() []Level {
+	t := levels.Load()
+	var all []Level
+	for l, info := range t {
+		if info != nil {
+			all = append(all, Level(l))
+		}
+	}
+	return all
+}
+
+func (l Level) info() *levelInfo {
+	return levels.Load()[l]
+}
+
+// allowed 不低于threshold或为不过滤的级别时返回true
+func (l Level) allowed(threshold Level) bool {
+	if l >= threshold {
+		return true
+	}
+	info := l.info()
+	return info != nil && info.unfiltered
+}
+
+func (l Level) String() string {
+	if info := l.info(); info != nil {
+		return info.name
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+func (l Level) shortName() string {
+	if info := l.info(); info != nil {
+		return info.short
+	}
+	return l.String()
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// Set 实现flag.Value
+func (l *Level) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
+
+// ParseLevel 按名称解析级别，不区分大小写，也接受级别的数值
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	if name == "WARNING" {
+		return WarnLevel, nil
+	}
+	t := levels.Load()
+	for l, info := range t {
+		if info != nil && info.name == name {
+			return Level(l), nil
+		}
+	}
+	if n, err := strconv.ParseUint(name, 10, 8); err == nil && t[n] != nil {
+		return Level(n), nil
+	}
+	return 0, fmt.Errorf("cuslog: unknown level %q", s)
+}
@@ -0,0 +1,93 @@
+package cuslog
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]Level{
+		"trace": TraceLevel, "Debug": DebugLevel, " INFO ": InfoLevel, "warning": WarnLevel,
+		"audit": AuditLevel, "fatal": FatalLevel, "50": ErrorLevel,
+	}
+	for s, want := range cases {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"verbose", "11", ""} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("ParseLevel(%q) should fail", s)
+		}
+	}
+}
+
+func TestLevelTextAndFlag(t *testing.T) {
+	text, _ := WarnLevel.MarshalText()
+	var l Level
+	if err := l.UnmarshalText(text); err != nil || l != WarnLevel {
+		t.Errorf("round trip = %v, %v", l, err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := InfoLevel
+	fs.Var(&level, "level", "log level")
+	if err := fs.Parse([]string{"-level", "error"}); err != nil || level != ErrorLevel {
+		t.Errorf("flag = %v, %v", level, err)
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	const noticeLevel Level = 33
+	//级别注册是全局的，测试结束后恢复，-count多次运行时不会重复注册
+	saved := levels.Load()
+	defer levels.Store(saved)
+	if err := RegisterLevel(noticeLevel, "notice", WithLevelColor("34"), WithLevelSyslogSeverity(severityNotice)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLevel(noticeLevel, "other"); err == nil {
+		t.Error("duplicate level value should be rejected")
+	}
+	if err := RegisterLevel(34, "Notice"); err == nil {
+		t.Error("duplicate level name should be rejected")
+	}
+	if got, err := ParseLevel("NOTICE"); err != nil || got != noticeLevel {
+		t.Errorf("ParseLevel(NOTICE) = %v, %v", got, err)
+	}
+
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithLevel(InfoLevel),
+		WithAppenders(NewAppender(buf, noticeLevel, &PatternFormatter{Layout: "%p{short} %m%n"})))
+	l.Info("filtered by appender")
+	l.Log(noticeLevel, "custom")
+	if got := buf.String(); got != "NOT custom\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// AUDIT不受级别过滤，但不应被当作比ERROR严重的级别
+func TestAuditLevelIsNotSevere(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithLevel(InfoLevel),
+		WithStacktraceLevel(ErrorLevel), WithFlightRecorder(8, ErrorLevel))
+	l.Debug("buffered detail")
+	l.Log(AuditLevel, "user alice logged in")
+	lines := buf.Lines()
+	//调用栈按行输出在日志之后，只有一行说明没有记录调用栈，也没有补写缓冲的日志
+	if len(lines) != 1 || !strings.Contains(lines[0], "AUDIT") {
+		t.Fatalf("audit should be written alone, got %q", buf.String())
+	}
+	l.Error("failure")
+	if lines = buf.Lines(); len(lines) < 4 || !strings.Contains(lines[1], "buffered detail") ||
+		!strings.Contains(lines[2], "failure") || !strings.HasPrefix(lines[3], "\t") {
+		t.Errorf("error should dump the flight recorder, got %q", buf.String())
+	}
+
+	strict := New(WithOutput(buf), WithLevel(FatalLevel), WithAppenders(NewAppender(buf, FatalLevel, nil)))
+	SetNamedLevel("audit", FatalLevel)
+	defer SetLevelRules("")
+	strict.Named("audit").Log(AuditLevel, "always written")
+	if !strings.Contains(buf.String(), "always written") {
+		t.Error("audit should pass logger, appender and named levels")
+	}
+}
@@ -1,27 +1,45 @@
 package cuslog
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"os"
 	"sync"
-	"unsafe"
+	"sync/atomic"
 )
 
 type logger struct {
-	opt       *options
-	mu        sync.Mutex
+	//配置为不可变快照，修改时整体替换，写入路径不加锁
+	opt *atomic.Pointer[options]
+	//只用于串行化修改配置的操作
+	mu        *sync.Mutex
 	entryPool *sync.Pool
+	fields    []field
+	//AddCallerSkip设置的额外调用层数
+	callerSkip int
+	//Named设置的名称，为空时使用WithName设置的名称
+	name string
+	//由同名的子logger共享，未命名的logger为nil
+	levelCache *atomic.Pointer[cachedLevel]
+	//FlightScope创建的缓冲区
+	flight *flightRing
 }
 
 var std = New()
 
 func New(opt ...Option) *logger {
-	logger := &logger{opt: initOptions(opt...)}
+	logger := &logger{opt: &atomic.Pointer[options]{}, mu: &sync.Mutex{}}
+	logger.opt.Store(initOptions(opt...))
 	logger.entryPool = &sync.Pool{New: func() interface{} { return entry(logger) }}
 	return logger
 }
 
+// clone 子logger与父logger共享配置、锁和entry池
+func (l *logger) clone() *logger {
+	c := *l
+	return &c
+}
+
 func StdLogger() *logger {
 	return std
 }
@@ -30,11 +48,16 @@ func SetOptions(opts ...Option) {
 	std.SetOptions(opts...)
 }
 
+// SetOptions 在当前配置的副本上应用opts后整体替换，正在写入的entry继续使用旧配置
 func (l *logger) SetOptions(opts ...Option) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	for _, opt := range opts {
-		opt(l.opt)
+	old := l.opt.Load()
+	opt := old.apply(opts...)
+	l.opt.Store(opt)
+	l.mu.Unlock()
+	//替换异步写入时，写出并关闭原队列
+	if old.async != nil && old.async != opt.async {
+		old.async.close()
 	}
 }
 
@@ -42,13 +65,56 @@ func Writer() io.Writer {
 	return std
 }
 
+// Write 按行写入，每行一条stdLevel级别的日志，满足io.Writer的约定返回len(data)
 func (l *logger) Write(data []byte) (int, error) {
-	l.entry().write(l.opt.stdLevel, FmtEmptySeparate, *(*string)(unsafe.Pointer(&data)))
-	return 0, nil
+	l.writeLines(l.opt.Load().stdLevel, data, 1)
+	return len(data), nil
+}
+
+// writeLines 去掉末尾换行后按行拆分，跳过空行；data会被复制，调用方可以复用
+//
+// skip为writeLines之上需要额外跳过的调用层数
+func (l *logger) writeLines(level Level, data []byte, skip int) {
+	data = bytes.TrimRight(data, "\r\n")
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		e := l.entry()
+		e.skip = skip
+		e.write(level, FmtEmptySeparate, string(line))
+	}
 }
 
 func (l *logger) entry() *Entry {
-	return l.entryPool.Get().(*Entry)
+	e := l.entryPool.Get().(*Entry)
+	//entry池由父子logger共享，取出时绑定当前logger
+	e.logger = l
+	return e
+}
+
+func (l *logger) Trace(args ...interface{}) {
+	l.entry().write(TraceLevel, FmtEmptySeparate, args...)
+}
+
+func (l *logger) Tracef(format string, args ...interface{}) {
+	l.entry().write(TraceLevel, format, args...)
+}
+
+// Log 以任意级别写入，包括自定义级别；Panic、Fatal级别只写入日志，不会panic或退出
+func (l *logger) Log(level Level, args ...interface{}) {
+	l.entry().write(level, FmtEmptySeparate, args...)
+}
+
+func (l *logger) Logf(level Level, format string, args ...interface{}) {
+	l.entry().write(level, format, args...)
 }
 
 func (l *logger) Debug(args ...interface{}) {
@@ -81,14 +147,47 @@ func (l *logger) Errorf(format string, args ...interface{}) {
 	l.entry().write(ErrorLevel, format, args...)
 }
 
+// DPanic 开发模式下写入后panic，否则只写入日志
+func (l *logger) DPanic(args ...interface{}) {
+	l.entry().write(DPanicLevel, FmtEmptySeparate, args...)
+	if l.development() {
+		l.panic(fmt.Sprint(args...))
+	}
+}
+func (l *logger) DPanicf(format string, args ...interface{}) {
+	l.entry().write(DPanicLevel, format, args...)
+	if l.development() {
+		l.panic(fmt.Sprintf(format, args...))
+	}
+}
+
+func (l *logger) Panic(args ...interface{}) {
+	l.entry().write(PanicLevel, FmtEmptySeparate, args...)
+	l.panic(fmt.Sprint(args...))
+}
+func (l *logger) Panicf(format string, args ...interface{}) {
+	l.entry().write(PanicLevel, format, args...)
+	l.panic(fmt.Sprintf(format, args...))
+}
+
 func (l *logger) Fatal(args ...interface{}) {
 	l.entry().write(FatalLevel, FmtEmptySeparate, args...)
+	l.exit(1)
 }
 func (l *logger) Fatalf(format string, args ...interface{}) {
-	l.entry().write(FatalLevel, format, args)
+	l.entry().write(FatalLevel, format, args...)
+	l.exit(1)
 }
 
 // std logger
+func Trace(args ...interface{}) {
+	std.entry().write(TraceLevel, FmtEmptySeparate, args...)
+}
+
+func Log(level Level, args ...interface{}) {
+	std.entry().write(level, FmtEmptySeparate, args...)
+}
+
 func Debug(args ...interface{}) {
 	std.entry().write(DebugLevel, FmtEmptySeparate, args...)
 }
@@ -105,14 +204,29 @@ func Error(args ...interface{}) {
 	std.entry().write(ErrorLevel, FmtEmptySeparate, args...)
 }
 
+func DPanic(args ...interface{}) {
+	std.entry().write(DPanicLevel, FmtEmptySeparate, args...)
+	if std.development() {
+		std.panic(fmt.Sprint(args...))
+	}
+}
+
 func Panic(args ...interface{}) {
 	std.entry().write(PanicLevel, FmtEmptySeparate, args...)
-	panic(fmt.Sprint(args...))
+	std.panic(fmt.Sprint(args...))
 }
 
 func Fatal(args ...interface{}) {
 	std.entry().write(FatalLevel, FmtEmptySeparate, args...)
-	os.Exit(1)
+	std.exit(1)
+}
+
+func Tracef(format string, args ...interface{}) {
+	std.entry().write(TraceLevel, format, args...)
+}
+
+func Logf(level Level, format string, args ...interface{}) {
+	std.entry().write(level, format, args...)
 }
 
 func Debugf(format string, args ...interface{}) {
@@ -131,12 +245,19 @@ func Errorf(format string, args ...interface{}) {
 	std.entry().write(ErrorLevel, format, args...)
 }
 
+func DPanicf(format string, args ...interface{}) {
+	std.entry().write(DPanicLevel, format, args...)
+	if std.development() {
+		std.panic(fmt.Sprintf(format, args...))
+	}
+}
+
 func Panicf(format string, args ...interface{}) {
 	std.entry().write(PanicLevel, format, args...)
-	panic(fmt.Sprintf(format, args...))
+	std.panic(fmt.Sprintf(format, args...))
 }
 
 func Fatalf(format string, args ...interface{}) {
 	std.entry().write(FatalLevel, format, args...)
-	os.Exit(1)
+	std.exit(1)
 }
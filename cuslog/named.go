@@ -0,0 +1,149 @@
+package cuslog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// levelRules 按logger名称配置的级别，整体替换，generation用于使logger中缓存的级别失效
+type levelRules struct {
+	generation uint64
+	levels     map[string]Level
+}
+
+var (
+	levelRulesMu sync.Mutex
+	//为nil表示没有配置规则
+	currentLevelRules atomic.Pointer[levelRules]
+)
+
+// cachedLevel logger解析出的级别，ok为false表示没有匹配的规则
+type cachedLevel struct {
+	generation uint64
+	level      Level
+	ok         bool
+}
+
+// Named 返回名称为"父名称.name"的子logger，父logger没有名称时使用WithName设置的名称
+func (l *logger) Named(name string) *logger {
+	if name == "" {
+		return l
+	}
+	c := l.clone()
+	if parent := l.Name(); parent != "" {
+		name = parent + "." + name
+	}
+	c.name = name
+	c.levelCache = &atomic.Pointer[cachedLevel]{}
+	return c
+}
+
+func Named(name string) *logger {
+	return std.Named(name)
+}
+
+// Name 返回logger的名称
+func (l *logger) Name() string {
+	if l.name != "" {
+		return l.name
+	}
+	return l.opt.Load().name
+}
+
+// SetLevelRules 替换所有按名称配置的级别，spec格式为 app=info,app.db=debug，
+// 没有名称的一项如 warn 对所有logger生效；logger使用名称最长匹配的规则，app.db匹配app.db.pool但不匹配app.dbx，
+// 没有匹配的规则时使用WithLevel设置的级别
+func SetLevelRules(spec string) error {
+	levels := make(map[string]Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, levelName := "", item
+		if i := strings.LastIndexByte(item, '='); i >= 0 {
+			name, levelName = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		}
+		level, err := ParseLevel(levelName)
+		if err != nil {
+			return fmt.Errorf("cuslog: invalid level rule %q: unknown level %q", item, levelName)
+		}
+		levels[name] = level
+	}
+	levelRulesMu.Lock()
+	defer levelRulesMu.Unlock()
+	storeLevelRules(levels)
+	return nil
+}
+
+// SetNamedLevel 增加或修改名称为name的规则，name为空时对所有logger生效
+func SetNamedLevel(name string, level Level) {
+	levelRulesMu.Lock()
+	defer levelRulesMu.Unlock()
+	levels := make(map[string]Level)
+	if rules := currentLevelRules.Load(); rules != nil {
+		for k, v := range rules.levels {
+			levels[k] = v
+		}
+	}
+	levels[name] = level
+	storeLevelRules(levels)
+}
+
+// storeLevelRules 调用方持有levelRulesMu
+func storeLevelRules(levels map[string]Level) {
+	var generation uint64 = 1
+	if rules := currentLevelRules.Load(); rules != nil {
+		generation = rules.generation + 1
+	}
+	if len(levels) == 0 {
+		//generation仍需递增，清空后再设置规则时旧的缓存不能复用
+		levels = nil
+	}
+	currentLevelRules.Store(&levelRules{generation: generation, levels: levels})
+}
+
+// lookup 从完整名称开始逐级去掉最后一段查找规则，最后查找对所有logger生效的规则
+func (r *levelRules) lookup(name string) (Level, bool) {
+	for {
+		if level, ok := r.levels[name]; ok {
+			return level, true
+		}
+		if name == "" {
+			return 0, false
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			name = ""
+		} else {
+			name = name[:i]
+		}
+	}
+}
+
+// level 返回logger生效的级别，规则未变化时使用缓存
+func (l *logger) level(opt *options) Level {
+	rules := currentLevelRules.Load()
+	if rules == nil || rules.levels == nil {
+		return opt.level
+	}
+	if l.levelCache == nil {
+		//未命名的logger使用WithName设置的名称
+		if level, ok := rules.lookup(opt.name); ok {
+			return level
+		}
+		return opt.level
+	}
+	cached := l.levelCache.Load()
+	if cached == nil || cached.generation != rules.generation {
+		cached = &cachedLevel{generation: rules.generation}
+		cached.level, cached.ok = rules.lookup(l.name)
+		l.levelCache.Store(cached)
+	}
+	if cached.ok {
+		return cached.level
+	}
+	return opt.level
+}
@@ -0,0 +1,97 @@
+package cuslog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setLevelRules 设置全局规则，测试结束后清空
+func setLevelRules(t *testing.T, spec string) {
+	t.Helper()
+	if err := SetLevelRules(spec); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetLevelRules("") })
+}
+
+func TestNamed(t *testing.T) {
+	l := New(WithName("app"))
+	if got := l.Named("db").Named("pool").Name(); got != "app.db.pool" {
+		t.Errorf("name = %s, want app.db.pool", got)
+	}
+	if got := New().Named("db").Name(); got != "db" {
+		t.Errorf("name = %s, want db", got)
+	}
+}
+
+func TestLevelRulesLongestPrefix(t *testing.T) {
+	setLevelRules(t, "error, app=info, app.db=debug")
+	buf := &lockedBuffer{}
+	root := New(WithOutput(buf), WithDisableCaller(true), WithLevel(WarnLevel))
+	app := root.Named("app")
+	cases := []struct {
+		l     *logger
+		level Level
+		want  bool
+	}{
+		{app, DebugLevel, false},
+		{app, InfoLevel, true},
+		{app.Named("db"), DebugLevel, true},
+		{app.Named("db").Named("pool"), DebugLevel, true},
+		//app.db不匹配app.dbx
+		{app.Named("dbx"), DebugLevel, false},
+		{app.Named("dbx"), InfoLevel, true},
+		//未命名的logger使用对所有logger生效的规则
+		{root, WarnLevel, false},
+		{root, ErrorLevel, true},
+	}
+	for _, c := range cases {
+		before := len(buf.Lines())
+		c.l.Log(c.level, "message")
+		if got := len(buf.Lines()) > before; got != c.want {
+			t.Errorf("%s %s: written = %v, want %v", c.l.Name(), c.level, got, c.want)
+		}
+	}
+}
+
+func TestLevelRulesRuntimeChange(t *testing.T) {
+	setLevelRules(t, "app=info")
+	buf := &lockedBuffer{}
+	db := New(WithOutput(buf), WithDisableCaller(true)).Named("app").Named("db")
+	db.Debug("hidden")
+	SetNamedLevel("app.db", DebugLevel)
+	db.Debug("shown")
+	//清空规则后使用WithLevel设置的级别
+	SetLevelRules("")
+	db.Trace("hidden")
+	db.Debug("default")
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "shown") || !strings.Contains(got, "default") {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestLevelRulesInvalid(t *testing.T) {
+	if err := SetLevelRules("app=verbose"); err == nil {
+		t.Error("unknown level should be rejected")
+	}
+}
+
+func TestLevelRulesConcurrent(t *testing.T) {
+	setLevelRules(t, "app=info")
+	l := New(WithOutput(&lockedBuffer{})).Named("app")
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if g == 0 && i%20 == 0 {
+					SetNamedLevel("app", []Level{TraceLevel, DebugLevel, InfoLevel}[i/20%3])
+				}
+				l.Debug("concurrent")
+			}
+		}(g)
+	}
+	wg.Wait()
+}
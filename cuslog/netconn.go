@@ -0,0 +1,96 @@
+package cuslog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrConnBackoff 连接断开后处于退避等待中，本次写入被丢弃
+var ErrConnBackoff = errors.New("cuslog: connection is backing off")
+
+// netConn 断线后自动重连的网络连接，重连失败时按指数退避，退避期间的写入直接返回ErrConnBackoff
+//
+// 第一次写入时才建立连接，启动时服务不可用也能创建writer
+type netConn struct {
+	dial       func() (net.Conn, error)
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu          sync.Mutex
+	conn        net.Conn
+	backoff     time.Duration
+	nextAttempt time.Time
+	closed      bool
+}
+
+func newNetConn(dial func() (net.Conn, error), minBackoff, maxBackoff time.Duration) *netConn {
+	return &netConn{dial: dial, minBackoff: minBackoff, maxBackoff: maxBackoff}
+}
+
+// write 在锁内调用send，send失败时立即重连重试一次
+func (c *netConn) write(send func(conn net.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return os.ErrClosed
+	}
+	if c.conn == nil {
+		if time.Now().Before(c.nextAttempt) {
+			return ErrConnBackoff
+		}
+		if err := c.reconnect(); err != nil {
+			return err
+		}
+	}
+	if err := send(c.conn); err != nil {
+		//对端重启等情况下连接已失效
+		c.conn.Close()
+		c.conn = nil
+		if err = c.reconnect(); err != nil {
+			return err
+		}
+		if err = send(c.conn); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			c.fail()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *netConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *netConn) reconnect() error {
+	conn, err := c.dial()
+	if err != nil {
+		c.fail()
+		return err
+	}
+	c.conn = conn
+	c.backoff = 0
+	return nil
+}
+
+// fail 指数退避，下次写入时再尝试连接
+func (c *netConn) fail() {
+	if c.backoff == 0 {
+		c.backoff = c.minBackoff
+	} else if c.backoff *= 2; c.backoff > c.maxBackoff {
+		c.backoff = c.maxBackoff
+	}
+	c.nextAttempt = time.Now().Add(c.backoff)
+}
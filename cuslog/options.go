@@ -2,56 +2,131 @@ package cuslog
 
 import (
 	"io"
+	"log/slog"
 	"os"
+	"reflect"
+	"sync"
 )
 
 const (
 	FmtEmptySeparate = ""
 )
 
-type Level uint8
-
-const (
-	DebugLevel Level = iota
-	InfoLevel
-	WarnLevel
-	ErrorLevel
-	PanicLevel
-	FatalLevel
-)
-
-var LevelNameMapping = map[Level]string{
-	DebugLevel: "DEBUG",
-	InfoLevel:  "INFO",
-	WarnLevel:  "WARN",
-	ErrorLevel: "ERROR",
-	PanicLevel: "PANIC",
-	FatalLevel: "FATAL",
-}
-
 type options struct {
 	output        io.Writer
+	outputMu      *sync.Mutex
 	level         Level
 	stdLevel      Level
 	formatter     Formatter
 	disableCaller bool
+	callerSkip    int
+	callerPath    CallerPath
+	callerLevel   Level
+
+	stacktrace      bool
+	stacktraceLevel Level
+	name            string
+	development     bool
+	exitFunc        func(int)
+
+	hooks            LevelHooks
+	hookErrorHandler HookErrorHandler
+
+	appenders     []*Appender
+	formatters    []Formatter
+	appenderSlots []int
+	appenderLocks []*sync.Mutex
+
+	slogHandler slog.Handler
+
+	redactor *Redactor
+	dedup    *deduper
+	flight   *flightRecorder
+
+	errorHandler ErrorHandler
+	fallback     *fallbackWriter
+
+	async            *asyncWriter
+	asyncBypass      bool
+	asyncBypassLevel Level
 }
 
 type Option func(options2 *options)
 
 func initOptions(opts ...Option) *options {
-	o := &options{}
+	//Level的零值不是有效级别，默认级别需要显式设置
+	return (&options{level: DebugLevel, stdLevel: DebugLevel}).apply(opts...)
+}
+
+// apply 在o的副本上应用opts并返回新的快照，o本身不变
+func (o *options) apply(opts ...Option) *options {
+	c := *o
 	for _, opt := range opts {
-		opt(o)
+		opt(&c)
 	}
-	if o.output == nil {
-		o.output = os.Stderr
+	if c.output == nil {
+		c.output = os.Stderr
 	}
-	if o.formatter == nil {
+	c.inheritLocks(o)
+	if c.fallback == nil {
+		c.fallback = newFallbackWriter(os.Stderr)
+	}
+	if c.formatter == nil {
 		//默认formmater为TextFormatter
-		o.formatter = &TextFormatter{}
+		c.formatter = &TextFormatter{}
+	}
+	return &c
+}
+
+// inheritLocks 为output和每个appender分配写入锁，只串行化对同一output的写入
+//
+// 锁保存在快照中：新快照沿用旧快照中同一output的锁，快照内相同的output共用一把锁；
+// 不再使用的output随旧快照一起释放。独立创建的logger之间不共享锁，共用output时应使用子logger
+func (c *options) inheritLocks(old *options) {
+	c.outputMu = old.lockOf(c.output)
+	if c.outputMu == nil {
+		c.outputMu = &sync.Mutex{}
 	}
-	return o
+	locks := make([]*sync.Mutex, len(c.appenders))
+	for i, a := range c.appenders {
+		if locks[i] = old.lockOf(a.Output); locks[i] != nil {
+			continue
+		}
+		if sameWriter(a.Output, c.output) {
+			locks[i] = c.outputMu
+			continue
+		}
+		for j := 0; j < i && locks[i] == nil; j++ {
+			if sameWriter(a.Output, c.appenders[j].Output) {
+				locks[i] = locks[j]
+			}
+		}
+		if locks[i] == nil {
+			locks[i] = &sync.Mutex{}
+		}
+	}
+	c.appenderLocks = locks
+}
+
+// lockOf 返回o中output使用的锁，没有时返回nil
+func (o *options) lockOf(output io.Writer) *sync.Mutex {
+	if o.outputMu != nil && sameWriter(o.output, output) {
+		return o.outputMu
+	}
+	for i, a := range o.appenders {
+		if i < len(o.appenderLocks) && sameWriter(a.Output, output) {
+			return o.appenderLocks[i]
+		}
+	}
+	return nil
+}
+
+// sameWriter 不可比较的output直接比较会panic，视为不同的output
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil || !reflect.ValueOf(a).Comparable() || !reflect.ValueOf(b).Comparable() {
+		return false
+	}
+	return a == b
 }
 
 func WithOutput(output io.Writer) Option {
@@ -78,8 +153,92 @@ func WithFormatter(f Formatter) Option {
 	})
 }
 
+func WithName(name string) Option {
+	return Option(func(options2 *options) {
+		options2.name = name
+	})
+}
+
 func WithDisableCaller(d bool) Option {
 	return Option(func(options2 *options) {
 		options2.disableCaller = d
 	})
 }
+
+// WithCallerSkip 额外跳过n层调用，封装cuslog时使调用位置指向封装函数的调用方
+func WithCallerSkip(n int) Option {
+	return Option(func(options2 *options) {
+		options2.callerSkip = n
+	})
+}
+
+func WithCallerPath(path CallerPath) Option {
+	return Option(func(options2 *options) {
+		options2.callerPath = path
+	})
+}
+
+// WithCallerLevel 只有不低于level的日志才获取调用位置
+func WithCallerLevel(level Level) Option {
+	return Option(func(options2 *options) {
+		options2.callerLevel = level
+	})
+}
+
+// WithStacktraceLevel 不低于level的日志记录调用栈
+func WithStacktraceLevel(level Level) Option {
+	return Option(func(options2 *options) {
+		options2.stacktrace = true
+		options2.stacktraceLevel = level
+	})
+}
+
+// WithRedactor 在格式化和触发hook之前替换敏感数据
+func WithRedactor(r *Redactor) Option {
+	return Option(func(options2 *options) {
+		options2.redactor = r
+	})
+}
+
+func WithHooks(hooks ...Hook) Option {
+	return Option(func(options2 *options) {
+		for _, hook := range hooks {
+			options2.hooks = options2.hooks.add(hook)
+		}
+	})
+}
+
+func WithHookErrorHandler(handler HookErrorHandler) Option {
+	return Option(func(options2 *options) {
+		options2.hookErrorHandler = handler
+	})
+}
+
+// WithAsync 开启异步写入，queueSize为队列长度，policy为队列满时的处理策略
+func WithAsync(queueSize int, policy AsyncPolicy) Option {
+	return Option(func(options2 *options) {
+		options2.async = newAsyncWriter(queueSize, policy)
+	})
+}
+
+// WithAsyncBypassLevel 不低于level的entry在队列满时阻塞等待，不会被丢弃
+func WithAsyncBypassLevel(level Level) Option {
+	return Option(func(options2 *options) {
+		options2.asyncBypass = true
+		options2.asyncBypassLevel = level
+	})
+}
+
+// WithDevelopment 开发模式下DPanic会panic
+func WithDevelopment(d bool) Option {
+	return Option(func(options2 *options) {
+		options2.development = d
+	})
+}
+
+// WithExitFunc 替换Fatal使用的os.Exit，便于测试
+func WithExitFunc(exit func(int)) Option {
+	return Option(func(options2 *options) {
+		options2.exitFunc = exit
+	})
+}
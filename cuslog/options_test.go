@@ -0,0 +1,84 @@
+package cuslog
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// concurrencyWriter 记录同时进入Write的调用数量
+type concurrencyWriter struct {
+	active  int32
+	overlap int32
+	writes  int32
+}
+
+func (w *concurrencyWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt32(&w.active, 1) > 1 {
+		atomic.StoreInt32(&w.overlap, 1)
+	}
+	//让出调度，扩大并发写入的窗口
+	runtime.Gosched()
+	atomic.AddInt32(&w.writes, 1)
+	atomic.AddInt32(&w.active, -1)
+	return len(p), nil
+}
+
+func TestOutputLockSharedByWriter(t *testing.T) {
+	w := &concurrencyWriter{}
+	a := New(WithOutput(w), WithAppenders(NewAppender(w, InfoLevel, &JsonFormatter{}), NewAppender(w, InfoLevel, nil)))
+	if opt := a.opt.Load(); opt.appenderLocks[0] != opt.outputMu || opt.appenderLocks[1] != opt.outputMu {
+		t.Error("appenders sharing a writer should share its lock")
+	}
+	before := a.opt.Load().outputMu
+	a.SetOptions(WithOutput(w), WithLevel(InfoLevel))
+	if a.opt.Load().outputMu != before {
+		t.Error("SetOptions with the same writer should keep its lock")
+	}
+	a.SetOptions(WithOutput(&concurrencyWriter{}), WithAppenders(NewAppender(w, InfoLevel, nil)))
+	if opt := a.opt.Load(); opt.appenderLocks[0] != before || opt.outputMu == before {
+		t.Error("a writer moved from output to an appender should keep its lock")
+	}
+	a.SetOptions(WithOutput(w), WithAppenders())
+	if a.opt.Load().outputMu != before {
+		t.Error("a writer moved back to output should keep its lock")
+	}
+	b := a.Named("child")
+	c := a.WithField("k", "v")
+
+	var wg sync.WaitGroup
+	for _, l := range []*logger{a, b, c} {
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(l *logger) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					l.Info("concurrent")
+					if i == 100 && l == a {
+						l.SetOptions(WithOutput(w), WithLevel(DebugLevel))
+					}
+				}
+			}(l)
+		}
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&w.overlap) != 0 {
+		t.Error("concurrent Write calls on the same writer")
+	}
+	if n := atomic.LoadInt32(&w.writes); n != 3*4*200 {
+		t.Errorf("got %d writes, want %d", n, 3*4*200)
+	}
+}
+
+func TestSetOptionsKeepsUnchangedOptions(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithLevel(WarnLevel), WithFormatter(&JsonFormatter{}))
+	l.SetOptions(WithDisableCaller(true))
+	l.Info("filtered")
+	l.Warn("kept")
+	lines := buf.Lines()
+	if len(lines) != 1 || lines[0][0] != '{' {
+		t.Errorf("got %q, want one json line", buf.String())
+	}
+}
@@ -0,0 +1,182 @@
+package cuslog
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SuppressedKey 恢复输出时记录期间被跳过的日志条数
+const SuppressedKey = "suppressed"
+
+type limitKind uint8
+
+const (
+	limitEveryN limitKind = iota
+	limitFirstN
+	limitEvery
+)
+
+// siteState 单个调用位置的计数，只使用原子操作
+type siteState struct {
+	count      uint64
+	last       int64
+	suppressed uint64
+}
+
+// siteStates 以调用位置的PC为key；调用方被内联到多处时同一行代码有多个PC，
+// 再按文件和行号在siteLines中共用同一个siteState
+var (
+	siteStates sync.Map
+	siteLines  sync.Map
+)
+
+// RateLimited EveryN、FirstN、Every的返回值，本次调用被限流时各方法不输出
+type RateLimited struct {
+	logger     *logger
+	suppressed uint64
+}
+
+// callSite 返回EveryN等函数的调用位置：Callers->0,callSite->1,EveryN->2,调用函数->3
+func callSite() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	return pcs[0]
+}
+
+// EveryN 同一调用位置每n次输出一次，第一次总是输出
+func (l *logger) EveryN(n int) RateLimited {
+	return l.limit(callSite(), limitEveryN, int64(n))
+}
+
+// FirstN 同一调用位置只输出前n次
+func (l *logger) FirstN(n int) RateLimited {
+	return l.limit(callSite(), limitFirstN, int64(n))
+}
+
+// Every 同一调用位置每隔d最多输出一次
+func (l *logger) Every(d time.Duration) RateLimited {
+	return l.limit(callSite(), limitEvery, int64(d))
+}
+
+func EveryN(n int) RateLimited {
+	return std.limit(callSite(), limitEveryN, int64(n))
+}
+
+func FirstN(n int) RateLimited {
+	return std.limit(callSite(), limitFirstN, int64(n))
+}
+
+func Every(d time.Duration) RateLimited {
+	return std.limit(callSite(), limitEvery, int64(d))
+}
+
+func (l *logger) limit(pc uintptr, kind limitKind, n int64) RateLimited {
+	s := siteStateOf(pc)
+	if !s.allow(kind, n) {
+		atomic.AddUint64(&s.suppressed, 1)
+		return RateLimited{}
+	}
+	return RateLimited{logger: l, suppressed: atomic.SwapUint64(&s.suppressed, 0)}
+}
+
+// siteStateOf 只在PC第一次出现时解析文件和行号
+func siteStateOf(pc uintptr) *siteState {
+	if v, ok := siteStates.Load(pc); ok {
+		return v.(*siteState)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	v, _ := siteLines.LoadOrStore(frame.File+":"+strconv.Itoa(frame.Line), &siteState{})
+	siteStates.Store(pc, v)
+	return v.(*siteState)
+}
+
+func (s *siteState) allow(kind limitKind, n int64) bool {
+	switch kind {
+	case limitEveryN:
+		if n <= 1 {
+			return true
+		}
+		return (atomic.AddUint64(&s.count, 1)-1)%uint64(n) == 0
+	case limitFirstN:
+		//超过n后不再递增，避免溢出
+		if atomic.LoadUint64(&s.count) >= uint64(n) {
+			return false
+		}
+		return atomic.AddUint64(&s.count, 1) <= uint64(n)
+	case limitEvery:
+		now := time.Now().UnixNano()
+		last := atomic.LoadInt64(&s.last)
+		if last != 0 && now-last < n {
+			return false
+		}
+		//并发时只有一个调用能更新成功
+		return atomic.CompareAndSwapInt64(&s.last, last, now)
+	}
+	return true
+}
+
+// write 有被跳过的日志时带上SuppressedKey字段
+func (r RateLimited) write(level Level, format string, args []interface{}) {
+	l := r.logger
+	if l == nil {
+		return
+	}
+	if r.suppressed > 0 {
+		l = l.WithField(SuppressedKey, r.suppressed)
+	}
+	e := l.entry()
+	//跳过RateLimited的方法
+	e.skip = 1
+	e.write(level, format, args...)
+}
+
+func (r RateLimited) Trace(args ...interface{}) {
+	r.write(TraceLevel, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Tracef(format string, args ...interface{}) {
+	r.write(TraceLevel, format, args)
+}
+
+func (r RateLimited) Debug(args ...interface{}) {
+	r.write(DebugLevel, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Debugf(format string, args ...interface{}) {
+	r.write(DebugLevel, format, args)
+}
+
+func (r RateLimited) Info(args ...interface{}) {
+	r.write(InfoLevel, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Infof(format string, args ...interface{}) {
+	r.write(InfoLevel, format, args)
+}
+
+func (r RateLimited) Warn(args ...interface{}) {
+	r.write(WarnLevel, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Warnf(format string, args ...interface{}) {
+	r.write(WarnLevel, format, args)
+}
+
+func (r RateLimited) Error(args ...interface{}) {
+	r.write(ErrorLevel, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Errorf(format string, args ...interface{}) {
+	r.write(ErrorLevel, format, args)
+}
+
+func (r RateLimited) Log(level Level, args ...interface{}) {
+	r.write(level, FmtEmptySeparate, args)
+}
+
+func (r RateLimited) Logf(level Level, format string, args ...interface{}) {
+	r.write(level, format, args)
+}
@@ -0,0 +1,103 @@
+package cuslog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resetRateLimits 计数按调用位置全局保存，-count多次运行时需要清空
+func resetRateLimits() {
+	for _, m := range []*sync.Map{&siteStates, &siteLines} {
+		m.Range(func(k, _ interface{}) bool {
+			m.Delete(k)
+			return true
+		})
+	}
+}
+
+func TestEveryN(t *testing.T) {
+	resetRateLimits()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	for i := 0; i < 10; i++ {
+		l.EveryN(4).Infof("attempt %d", i)
+	}
+	lines := buf.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), buf.String())
+	}
+	for i, want := range []string{"attempt 0", "attempt 4 suppressed=3", "attempt 8 suppressed=3"} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d = %q, want suffix %q", i, lines[i], want)
+		}
+	}
+	//调用位置指向调用方，而不是RateLimited的方法
+	if !strings.Contains(lines[0], "ratelimit_test.go") {
+		t.Errorf("unexpected caller: %q", lines[0])
+	}
+}
+
+func TestFirstN(t *testing.T) {
+	resetRateLimits()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	for i := 0; i < 5; i++ {
+		l.FirstN(2).Warn("deprecated option")
+	}
+	if n := len(buf.Lines()); n != 2 {
+		t.Errorf("got %d lines, want 2", n)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	resetRateLimits()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	//封装函数被内联到多处调用时仍是同一个调用位置
+	logEvery := func() { l.Every(50 * time.Millisecond).Error("upstream down") }
+	logEvery()
+	logEvery()
+	logEvery()
+	time.Sleep(60 * time.Millisecond)
+	logEvery()
+	lines := buf.Lines()
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "upstream down suppressed=2") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+// 不同的调用位置分别计数
+func TestRateLimitPerCallSite(t *testing.T) {
+	resetRateLimits()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	for i := 0; i < 3; i++ {
+		l.FirstN(1).Info("site a")
+		l.FirstN(1).Info("site b")
+	}
+	if got := buf.String(); strings.Count(got, "site a") != 1 || strings.Count(got, "site b") != 1 {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestEveryNConcurrent(t *testing.T) {
+	resetRateLimits()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.EveryN(10).Info("tick")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(buf.Lines()); n != 80 {
+		t.Errorf("got %d lines, want 80", n)
+	}
+}
@@ -0,0 +1,238 @@
+package cuslog
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RedactMode 敏感数据的替换方式
+type RedactMode uint8
+
+const (
+	// RedactMask 整体替换为[REDACTED]
+	RedactMask RedactMode = iota
+	// RedactLast4 只保留最后4个字符，如****1234
+	RedactLast4
+	// RedactHash 替换为HMAC-SHA256的前16个十六进制字符，相同的值得到相同结果，便于关联
+	RedactHash
+)
+
+const redactedText = "[REDACTED]"
+
+// RedactDetector 在日志内容中查找敏感数据，正则有分组时只替换第一个匹配到的分组
+type RedactDetector struct {
+	Name     string
+	re       *regexp.Regexp
+	validate func(s string) bool
+}
+
+// NewRedactDetector 使用自定义正则查找敏感数据
+func NewRedactDetector(name string, re *regexp.Regexp) RedactDetector {
+	return RedactDetector{Name: name, re: re}
+}
+
+// 内置的detector
+var (
+	// RedactCreditCard 13到19位的卡号，允许空格和-分隔，通过Luhn校验才替换
+	RedactCreditCard = RedactDetector{Name: "credit_card", re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), validate: luhnValid}
+	RedactEmail      = RedactDetector{Name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)}
+	// RedactBearerToken 只替换Bearer之后的token
+	RedactBearerToken = RedactDetector{Name: "bearer_token", re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)}
+	// RedactAWSKey AWS的access key id和aws_secret_access_key=后的secret key
+	RedactAWSKey = RedactDetector{Name: "aws_key", re: regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16})\b|(?i:aws_secret_access_key)["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)}
+	RedactJWT    = RedactDetector{Name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)}
+)
+
+// DefaultRedactDetectors NewRedactor未指定detector时使用
+var DefaultRedactDetectors = []RedactDetector{RedactCreditCard, RedactEmail, RedactBearerToken, RedactAWSKey, RedactJWT}
+
+// DefaultRedactKeys NewRedactor未指定字段名时使用
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "*token*", "authorization", "cookie", "api_key", "apikey"}
+
+type redactOptions struct {
+	keys      []string
+	detectors []RedactDetector
+	mode      RedactMode
+	hashKey   []byte
+}
+
+type RedactOption func(options2 *redactOptions)
+
+// WithRedactKeys 字段名匹配时替换字段值，支持*和?通配符，不区分大小写
+func WithRedactKeys(keys ...string) RedactOption {
+	return RedactOption(func(options2 *redactOptions) {
+		options2.keys = keys
+	})
+}
+
+// WithRedactDetectors 替换日志内容中被detector找到的敏感数据
+func WithRedactDetectors(detectors ...RedactDetector) RedactOption {
+	return RedactOption(func(options2 *redactOptions) {
+		options2.detectors = detectors
+	})
+}
+
+func WithRedactMode(mode RedactMode) RedactOption {
+	return RedactOption(func(options2 *redactOptions) {
+		options2.mode = mode
+	})
+}
+
+// WithRedactHashKey RedactHash使用的密钥，不设置时随机生成，只在进程内可关联
+func WithRedactHashKey(key []byte) RedactOption {
+	return RedactOption(func(options2 *redactOptions) {
+		options2.hashKey = key
+	})
+}
+
+// Redactor 在格式化之前替换字段和日志内容中的敏感数据，通过WithRedactor使用
+type Redactor struct {
+	opt redactOptions
+	//字段名是否需要替换的缓存
+	keyCache sync.Map
+}
+
+func NewRedactor(opts ...RedactOption) *Redactor {
+	o := redactOptions{keys: DefaultRedactKeys, detectors: DefaultRedactDetectors}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	keys := make([]string, len(o.keys))
+	for i, k := range o.keys {
+		keys[i] = strings.ToLower(k)
+	}
+	o.keys = keys
+	if o.mode == RedactHash && len(o.hashKey) == 0 {
+		o.hashKey = make([]byte, 32)
+		_, _ = rand.Read(o.hashKey)
+	}
+	return &Redactor{opt: o}
+}
+
+// Redact 替换e中的敏感数据，可以在Hook中单独使用
+func (r *Redactor) Redact(e *Entry) {
+	for k, v := range e.Map {
+		if r.sensitiveKey(k) {
+			e.Map[k] = r.replace(bytesToString(appendValue(nil, v)))
+		}
+	}
+	msg := e.messageBytes()
+	for i := range r.opt.detectors {
+		msg = r.scrub(&r.opt.detectors[i], msg)
+	}
+	e.message = msg
+	//Args替换为替换后的内容，直接读取Args的formatter和hook也拿不到原始数据
+	e.Format, e.argBuf[0] = FmtEmptySeparate, string(msg)
+	e.Args = e.argBuf[:]
+}
+
+// RedactString 替换s中被detector找到的敏感数据
+func (r *Redactor) RedactString(s string) string {
+	msg := []byte(s)
+	for i := range r.opt.detectors {
+		msg = r.scrub(&r.opt.detectors[i], msg)
+	}
+	return string(msg)
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	if v, ok := r.keyCache.Load(key); ok {
+		return v.(bool)
+	}
+	lower := strings.ToLower(key)
+	matched := false
+	for _, pattern := range r.opt.keys {
+		if ok, _ := path.Match(pattern, lower); ok {
+			matched = true
+			break
+		}
+	}
+	r.keyCache.Store(key, matched)
+	return matched
+}
+
+// scrub 没有匹配时返回原切片
+func (r *Redactor) scrub(d *RedactDetector, msg []byte) []byte {
+	matches := d.re.FindAllSubmatchIndex(msg, -1)
+	if matches == nil {
+		return msg
+	}
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		//有分组时只替换第一个匹配到的分组
+		for g := 2; g+1 < len(m); g += 2 {
+			if m[g] >= 0 {
+				start, end = m[g], m[g+1]
+				break
+			}
+		}
+		value := bytesToString(msg[start:end])
+		if d.validate != nil && !d.validate(value) {
+			continue
+		}
+		if out == nil {
+			out = make([]byte, 0, len(msg))
+		}
+		out = append(out, msg[last:start]...)
+		out = append(out, r.replace(value)...)
+		last = end
+	}
+	if out == nil {
+		return msg
+	}
+	return append(out, msg[last:]...)
+}
+
+func (r *Redactor) replace(value string) string {
+	switch r.opt.mode {
+	case RedactLast4:
+		n := utf8.RuneCountInString(value)
+		if n <= 4 {
+			return "****"
+		}
+		i := len(value)
+		for j := 0; j < 4; j++ {
+			_, size := utf8.DecodeLastRuneInString(value[:i])
+			i -= size
+		}
+		return "****" + value[i:]
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.opt.hashKey)
+		mac.Write([]byte(value))
+		sum := mac.Sum(nil)
+		buf := append(make([]byte, 0, 21), "hmac:"...)
+		for _, b := range sum[:8] {
+			buf = append(buf, hex[b>>4], hex[b&0xF])
+		}
+		return string(buf)
+	}
+	return redactedText
+}
+
+// luhnValid 忽略空格和-，校验卡号的Luhn校验位
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
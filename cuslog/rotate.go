@@ -0,0 +1,477 @@
+package cuslog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateInterval 按时间切割的周期
+type RotateInterval uint8
+
+const (
+	RotateNone RotateInterval = iota
+	RotateHourly
+	RotateDaily
+)
+
+const compressSuffix = ".gz"
+
+type rotateOptions struct {
+	maxSize      int64
+	interval     RotateInterval
+	symlink      string
+	compress     bool
+	maxAge       time.Duration
+	maxBackups   int
+	maxTotalSize int64
+}
+
+type RotateOption func(options2 *rotateOptions)
+
+// WithRotateMaxSize 单个文件超过size字节时切割
+func WithRotateMaxSize(size int64) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.maxSize = size
+	})
+}
+
+// WithRotateInterval 按小时或天切割
+func WithRotateInterval(interval RotateInterval) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.interval = interval
+	})
+}
+
+// WithRotateSymlink 指向当前文件的软链接路径，为空则不创建
+func WithRotateSymlink(path string) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.symlink = path
+	})
+}
+
+// WithRotateCompress 后台gzip压缩已切割的文件
+func WithRotateCompress(compress bool) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.compress = compress
+	})
+}
+
+// WithRotateMaxAge 删除修改时间早于maxAge的文件
+func WithRotateMaxAge(maxAge time.Duration) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.maxAge = maxAge
+	})
+}
+
+// WithRotateMaxBackups 最多保留n个已切割的文件
+func WithRotateMaxBackups(n int) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.maxBackups = n
+	})
+}
+
+// WithRotateMaxTotalSize 已切割文件总大小超过size字节时从最早的开始删除
+func WithRotateMaxTotalSize(size int64) RotateOption {
+	return RotateOption(func(options2 *rotateOptions) {
+		options2.maxTotalSize = size
+	})
+}
+
+// RotateWriter 可切割的文件output，可作为WithOutput的参数
+//
+// pattern为文件名模板，支持%Y %m %d %H %M %S %%，如 logs/app-%Y%m%d%H.log；
+// 同一周期内按大小切割时，在扩展名前追加序号，如 app-2022060419.1.log
+type RotateWriter struct {
+	pattern string
+	glob    string
+	match   *regexp.Regexp
+	opt     rotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	filename string
+	size     int64
+	period   time.Time
+	seq      int
+	//后台正在压缩的文件，切割时跳过
+	milling string
+
+	millCh chan struct{}
+	done   chan struct{}
+}
+
+func NewRotateWriter(pattern string, opts ...RotateOption) (*RotateWriter, error) {
+	//Glob返回的路径是Clean过的，与当前文件名比较前pattern也要Clean
+	pattern = filepath.Clean(pattern)
+	o := rotateOptions{symlink: filepath.Join(filepath.Dir(pattern), "current")}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w := &RotateWriter{
+		pattern: pattern,
+		glob:    patternGlob(pattern),
+		match:   patternRegexp(pattern),
+		opt:     o,
+		millCh:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if o.interval != RotateNone && !w.periodic() {
+		return nil, fmt.Errorf("cuslog: rotate pattern %q does not change between periods", pattern)
+	}
+	if err := os.MkdirAll(filepath.Dir(pattern), 0755); err != nil {
+		return nil, err
+	}
+	if err := w.open(time.Now(), 0); err != nil {
+		return nil, err
+	}
+	go w.runMill()
+	//启动时处理上次运行遗留的文件
+	w.millCh <- struct{}{}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	//切割失败时旧文件仍然打开，继续写入旧文件，下次写入时重试切割
+	now := time.Now()
+	if period := w.periodOf(now); !period.Equal(w.period) {
+		_ = w.rotate(now, false)
+	} else if w.opt.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opt.maxSize {
+		_ = w.rotate(now, true)
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即切割到新文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.rotate(time.Now(), true)
+}
+
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭当前文件并等待后台压缩、清理结束
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.file == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	close(w.millCh)
+	w.mu.Unlock()
+	<-w.done
+	return err
+}
+
+// rotate 新文件打开成功后才关闭旧文件，打开失败时继续使用旧文件，下次写入时重试
+func (w *RotateWriter) rotate(now time.Time, bySize bool) error {
+	seq := 0
+	if bySize {
+		seq = w.seq + 1
+	}
+	old := w.file
+	if err := w.open(now, seq); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		return err
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// open 打开now所在周期序号不小于seq的文件，已存在且未超过大小限制时追加写入
+//
+// 只在打开成功后修改w的状态
+func (w *RotateWriter) open(now time.Time, seq int) error {
+	period := w.periodOf(now)
+	base := strftime(w.pattern, period)
+	for {
+		name := seqName(base, seq)
+		info, err := os.Stat(name)
+		if err == nil && w.opt.maxSize > 0 && info.Size() >= w.opt.maxSize {
+			seq++
+			continue
+		}
+		if _, gzErr := os.Stat(name + compressSuffix); gzErr == nil || name == w.milling {
+			seq++
+			continue
+		}
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		w.file, w.filename, w.size, w.period, w.seq = f, name, 0, period, seq
+		if info != nil {
+			w.size = info.Size()
+		}
+		break
+	}
+	if w.opt.symlink != "" {
+		w.link()
+	}
+	return nil
+}
+
+// link 先创建临时软链接再rename，保证替换是原子的
+func (w *RotateWriter) link() {
+	target, err := filepath.Rel(filepath.Dir(w.opt.symlink), w.filename)
+	if err != nil {
+		target = w.filename
+	}
+	tmp := w.opt.symlink + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, w.opt.symlink)
+}
+
+func (w *RotateWriter) periodOf(t time.Time) time.Time {
+	switch w.opt.interval {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// periodic 相邻两个周期的文件名是否不同，相同时按时间切割会重新打开同一个文件
+func (w *RotateWriter) periodic() bool {
+	t := time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local)
+	next := t.Add(time.Hour)
+	if w.opt.interval == RotateDaily {
+		next = t.AddDate(0, 0, 1)
+	}
+	return strftime(w.pattern, t) != strftime(w.pattern, next)
+}
+
+func (w *RotateWriter) runMill() {
+	defer close(w.done)
+	for range w.millCh {
+		w.mill()
+	}
+}
+
+type segment struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// mill 压缩已切割的文件，并按保留策略删除旧文件
+//
+// Glob的结果可能包含同目录下其他文件，只处理匹配pattern的文件；Glob期间可能发生切割，新的当前文件也会出现在结果中，每个文件在处理前都要重新检查
+func (w *RotateWriter) mill() {
+	names, err := filepath.Glob(w.glob)
+	if err != nil {
+		return
+	}
+	var segments []segment
+	for _, name := range names {
+		if name == w.opt.symlink || !w.match.MatchString(name) || w.isCurrent(name) {
+			continue
+		}
+		info, err := os.Lstat(name)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if w.opt.compress && !strings.HasSuffix(name, compressSuffix) {
+			if err := w.compress(name); err == nil {
+				name += compressSuffix
+				if info, err = os.Stat(name); err != nil {
+					continue
+				}
+			}
+		}
+		segments = append(segments, segment{name: name, size: info.Size(), modTime: info.ModTime()})
+	}
+	//按修改时间从新到旧排序，时间相同时序号大的更新
+	sort.Slice(segments, func(i, j int) bool {
+		a, b := segments[i], segments[j]
+		if !a.modTime.Equal(b.modTime) {
+			return a.modTime.After(b.modTime)
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) > len(b.name)
+		}
+		return a.name > b.name
+	})
+	var total int64
+	for i, s := range segments {
+		total += s.size
+		if (w.opt.maxBackups > 0 && i >= w.opt.maxBackups) ||
+			(w.opt.maxAge > 0 && time.Since(s.modTime) > w.opt.maxAge) ||
+			(w.opt.maxTotalSize > 0 && total > w.opt.maxTotalSize) {
+			_ = os.Remove(s.name)
+		}
+	}
+}
+
+func (w *RotateWriter) isCurrent(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return name == w.filename
+}
+
+// compress 在锁内确认name不是当前文件后再压缩，压缩期间切割会跳过name
+func (w *RotateWriter) compress(name string) error {
+	w.mu.Lock()
+	if name == w.filename {
+		w.mu.Unlock()
+		return os.ErrExist
+	}
+	w.milling = name
+	w.mu.Unlock()
+	err := compressFile(name)
+	w.mu.Lock()
+	w.milling = ""
+	w.mu.Unlock()
+	return err
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(name+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + compressSuffix)
+		return err
+	}
+	//保留原文件的修改时间，用于按时间清理
+	_ = os.Chtimes(name+compressSuffix, info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
+
+func strftime(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			b.WriteString(strconv.Itoa(t.Year()))
+		case 'm':
+			b.WriteString(fmt.Sprintf("%02d", t.Month()))
+		case 'd':
+			b.WriteString(fmt.Sprintf("%02d", t.Day()))
+		case 'H':
+			b.WriteString(fmt.Sprintf("%02d", t.Hour()))
+		case 'M':
+			b.WriteString(fmt.Sprintf("%02d", t.Minute()))
+		case 'S':
+			b.WriteString(fmt.Sprintf("%02d", t.Second()))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+// patternGlob 将时间占位符替换为*，并匹配序号和压缩后缀
+func patternGlob(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '%' && i < len(pattern)-1 && strings.IndexByte("YmdHMS", pattern[i+1]) >= 0 {
+			b.WriteByte('*')
+			i++
+			continue
+		}
+		b.WriteByte(pattern[i])
+	}
+	ext := filepath.Ext(pattern)
+	base := strings.TrimSuffix(b.String(), ext)
+	return base + "*" + ext + "*"
+}
+
+// patternRegexp 只匹配本writer生成的文件名：时间占位符替换为定长数字，扩展名前可选序号，可选压缩后缀
+func patternRegexp(pattern string) *regexp.Regexp {
+	ext := filepath.Ext(pattern)
+	return regexp.MustCompile("^" + placeholderRegexp(strings.TrimSuffix(pattern, ext)) +
+		`(\.[1-9][0-9]*)?` + placeholderRegexp(ext) + "(" + regexp.QuoteMeta(compressSuffix) + ")?$")
+}
+
+func placeholderRegexp(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			b.WriteString("[0-9]{4}")
+		case 'm', 'd', 'H', 'M', 'S':
+			b.WriteString("[0-9]{2}")
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i-1 : i+1]))
+		}
+	}
+	return b.String()
+}
+
+func seqName(base string, seq int) string {
+	if seq == 0 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + strconv.Itoa(seq) + ext
+}
@@ -0,0 +1,266 @@
+package cuslog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readLines 读取目录下所有日志文件的行，.gz文件解压后读取
+func readLines(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, "*.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, compressSuffix) {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			r = gz
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+	}
+	return lines
+}
+
+func TestRotateWriterMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateMaxSize(20), WithRotateSymlink(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app.log", "app.1.log", "app.2.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if n := len(readLines(t, dir)); n != 5 {
+		t.Errorf("got %d lines, want 5", n)
+	}
+	if _, err := w.Write([]byte("x\n")); err != os.ErrClosed {
+		t.Errorf("write after close: got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestRotateWriterStaticPatternWithInterval(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateInterval(RotateHourly)); err == nil {
+		t.Error("pattern without time placeholder should be rejected for hourly rotation")
+	}
+	if _, err := NewRotateWriter(filepath.Join(dir, "app-%Y%m%d.log"), WithRotateInterval(RotateHourly)); err == nil {
+		t.Error("pattern without %H should be rejected for hourly rotation")
+	}
+	w, err := NewRotateWriter(filepath.Join(dir, "app-%Y%m%d.log"), WithRotateInterval(RotateDaily))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+}
+
+func TestRotateWriterOpenFailure(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateSymlink(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	//下一个文件名被目录占用，打开失败
+	blocker := filepath.Join(dir, "app.1.log")
+	if err := os.Mkdir(blocker, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err == nil {
+		t.Fatal("rotate should fail when the next file cannot be opened")
+	}
+	if _, err := w.Write([]byte("kept\n")); err != nil {
+		t.Fatalf("write after failed rotate: %v", err)
+	}
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("rotate after recovery: %v", err)
+	}
+	if _, err := w.Write([]byte("next\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil || string(data) != "kept\n" {
+		t.Errorf("app.log = %q, %v", data, err)
+	}
+	data, err = os.ReadFile(blocker)
+	if err != nil || string(data) != "next\n" {
+		t.Errorf("app.1.log = %q, %v", data, err)
+	}
+}
+
+func TestRotateWriterCompressRetention(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateCompress(true), WithRotateMaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte("line " + strconv.Itoa(i) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz, _ := filepath.Glob(filepath.Join(dir, "*.gz"))
+	if len(gz) != 2 {
+		t.Errorf("got %d compressed backups %v, want 2", len(gz), gz)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.4.log")); err != nil {
+		t.Errorf("current file should stay uncompressed: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(dir, "current"))
+	if err != nil || link != "app.4.log" {
+		t.Errorf("symlink = %q, %v", link, err)
+	}
+}
+
+// 切割和后台压缩并发进行时不能丢失日志
+func TestRotateWriterConcurrentCompress(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateMaxSize(256), WithRotateCompress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const goroutines, lines = 4, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				if _, err := w.Write([]byte("goroutine " + strconv.Itoa(g) + " line " + strconv.Itoa(i) + "\n")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readLines(t, dir)); n != goroutines*lines {
+		t.Errorf("got %d lines, want %d", n, goroutines*lines)
+	}
+}
+
+// 同一目录下文件名前缀相同的writer互不影响
+func TestRotateWriterSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	app, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateCompress(true), WithRotateMaxBackups(1), WithRotateSymlink(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errLog, err := NewRotateWriter(filepath.Join(dir, "app-error.log"), WithRotateSymlink(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "application.log")
+	if err := os.WriteFile(other, []byte("other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := app.Write([]byte("app\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := errLog.Write([]byte("error\n")); err != nil {
+		t.Fatal(err)
+	}
+	app.Close()
+	errLog.Close()
+	for name, want := range map[string]string{"app-error.log": "error\n", "application.log": "other\n"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+	if gz, _ := filepath.Glob(filepath.Join(dir, "*.gz")); len(gz) != 1 || filepath.Base(gz[0]) != "app.2.log.gz" {
+		t.Errorf("compressed backups = %v, want app.2.log.gz", gz)
+	}
+}
+
+func TestRotatePatternRegexp(t *testing.T) {
+	re := patternRegexp("logs/app-%Y%m%d.log")
+	for _, name := range []string{"logs/app-20220604.log", "logs/app-20220604.3.log", "logs/app-20220604.1.log.gz"} {
+		if !re.MatchString(name) {
+			t.Errorf("%s should match", name)
+		}
+	}
+	for _, name := range []string{"logs/app-error-20220604.log", "logs/app-2022064.log", "logs/app-20220604.log.tmp", "logs/app-20220604.0.log"} {
+		if re.MatchString(name) {
+			t.Errorf("%s should not match", name)
+		}
+	}
+}
+
+// 按大小切割失败时日志写入仍在使用的旧文件，下次写入时重试
+func TestRotateWriterWriteDuringOpenFailure(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotateMaxSize(10), WithRotateSymlink(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	//指向不存在目录的软链接，打开失败
+	blocker := filepath.Join(dir, "app.1.log")
+	if err := os.Symlink(filepath.Join(dir, "missing", "app.1.log"), blocker); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		if n, err := w.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("write %q = %d, %v", line, n, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "app.log")); err != nil || string(data) != "first\nsecond\n" {
+		t.Errorf("app.log = %q, %v", data, err)
+	}
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("third\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(blocker); err != nil || string(data) != "third\n" {
+		t.Errorf("app.1.log = %q, %v", data, err)
+	}
+}
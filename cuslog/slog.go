@@ -0,0 +1,177 @@
+package cuslog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogHandler 实现slog.Handler，使用cuslog的formatter和output输出slog的日志
+type SlogHandler struct {
+	logger *logger
+	//WithGroup设置的前缀，如 "req."
+	prefix string
+	fields []field
+}
+
+var _ slog.Handler = &SlogHandler{}
+
+// NewSlogHandler 返回使用l输出的slog.Handler，l为nil时使用std logger
+func NewSlogHandler(l *logger) *SlogHandler {
+	if l == nil {
+		l = std
+	}
+	return &SlogHandler{logger: l}
+}
+
+// Enabled 开启flight recorder时被过滤的日志也需要记录
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	opt := h.logger.opt.Load()
+	return opt.flight != nil || levelFromSlog(level).allowed(h.logger.level(opt))
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	e := h.logger.entry()
+	e.opt = h.logger.opt.Load()
+	e.Level = levelFromSlog(r.Level)
+	allowed := e.Level.allowed(h.logger.level(e.opt))
+	if !allowed && e.opt.flight == nil {
+		e.release()
+		return nil
+	}
+	e.Time = r.Time
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Name = h.logger.name
+	if e.Name == "" {
+		e.Name = e.opt.name
+	}
+	e.Format = FmtEmptySeparate
+	e.argBuf[0] = r.Message
+	e.Args = e.argBuf[:]
+	for _, f := range h.logger.fields {
+		e.setField(f.key, f.value)
+	}
+	for _, f := range h.fields {
+		e.setField(f.key, f.value)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(h.prefix, a, func(key string, value interface{}) {
+			e.setField(key, value)
+		})
+		return true
+	})
+	if !allowed {
+		if !e.opt.disableCaller {
+			e.pcs[0] = r.PC
+		}
+		e.recordFlight(false)
+		e.release()
+		return nil
+	}
+	if e.opt.flight != nil && e.Level >= e.opt.flight.trigger {
+		h.logger.dumpFlight(h.logger.flightRing(e.opt))
+	}
+	if !e.opt.disableCaller && e.Level >= e.opt.callerLevel && r.PC != 0 {
+		e.pcs[0] = r.PC
+		e.setCaller(r.PC)
+	}
+	if e.opt.stacktrace && e.Level >= e.opt.stacktraceLevel {
+		e.captureStack(2)
+	}
+	if e.opt.flight != nil {
+		e.recordFlight(true)
+	}
+	e.output()
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.fields = make([]field, len(h.fields), len(h.fields)+len(attrs))
+	copy(c.fields, h.fields)
+	for _, a := range attrs {
+		addSlogAttr(h.prefix, a, func(key string, value interface{}) {
+			c.fields = setField(c.fields, field{key: key, value: value})
+		})
+	}
+	return &c
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// addSlogAttr 按slog的规则展开属性，分组转换为以.分隔的字段名
+func addSlogAttr(prefix string, a slog.Attr, add func(key string, value interface{})) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		//key为空的分组直接展开到当前层级
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range attrs {
+			addSlogAttr(prefix, ga, add)
+		}
+		return
+	}
+	add(prefix+a.Key, a.Value.Any())
+}
+
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
+
+// levelToSlog 内置级别间隔10，slog间隔4，按比例换算，自定义级别落在相邻的slog级别之间
+func levelToSlog(level Level) slog.Level {
+	return slog.Level((int(level) - int(InfoLevel)) * 4 / 10)
+}
+
+// WithSlogHandler 将日志交给h处理，不再使用formatter和output
+func WithSlogHandler(h slog.Handler) Option {
+	return Option(func(options2 *options) {
+		options2.slogHandler = h
+	})
+}
+
+func (e *Entry) handleSlog() {
+	h := e.opt.slogHandler
+	level := levelToSlog(e.Level)
+	ctx := context.Background()
+	if !h.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(e.Time, level, string(e.messageBytes()), e.pcs[0])
+	for _, k := range e.Keys() {
+		r.AddAttrs(slog.Any(k, e.Map[k]))
+	}
+	if err := h.Handle(ctx, r); err != nil {
+		e.handleError(err, true)
+	}
+}
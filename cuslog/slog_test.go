@@ -0,0 +1,89 @@
+package cuslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandlerGroupsAndAttrs(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithFormatter(&JsonFormatter{}))
+	sl := slog.New(NewSlogHandler(l)).With("service", "api").WithGroup("req").With("id", 7)
+	sl.Info("handled",
+		slog.Group("user", "name", "alice", slog.Group("", "role", "admin")),
+		slog.Group("empty"),
+		slog.Int("status", 200))
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"service": "api", "req.id": float64(7), "req.user.name": "alice", "req.user.role": "admin", "req.status": float64(200),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["req.empty"]; ok {
+		t.Error("empty groups should be dropped")
+	}
+}
+
+func TestSlogHandlerLevelsAndSource(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithLevel(InfoLevel), WithFormatter(&LogfmtFormatter{}))
+	sl := slog.New(NewSlogHandler(l))
+	sl.Debug("filtered")
+	_, _, line, _ := runtime.Caller(0)
+	sl.Warn("slow")
+	got := buf.String()
+	if strings.Contains(got, "filtered") || !strings.Contains(got, "level=warn") {
+		t.Errorf("unexpected output: %q", got)
+	}
+	//Record.PC指向slog.Logger方法的调用方
+	if want := "caller=slog_test.go:" + strconv.Itoa(line+1); !strings.Contains(got, want) {
+		t.Errorf("want %s in %q", want, got)
+	}
+	for level, want := range map[slog.Level]Level{
+		slog.LevelDebug - 4: TraceLevel, slog.LevelDebug: DebugLevel, slog.LevelInfo + 1: InfoLevel,
+		slog.LevelWarn: WarnLevel, slog.LevelError + 4: ErrorLevel,
+	} {
+		if got := levelFromSlog(level); got != want {
+			t.Errorf("levelFromSlog(%v) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+// WithSlogHandler将cuslog的日志交给任意slog.Handler
+func TestWithSlogHandler(t *testing.T) {
+	var out bytes.Buffer
+	h := slog.NewJSONHandler(&out, &slog.HandlerOptions{AddSource: true, Level: slog.LevelInfo})
+	l := New(WithSlogHandler(h))
+	l.Debug("dropped by handler")
+	_, _, line, _ := runtime.Caller(0)
+	l.WithField("user", "alice").Warnf("retry %d", 3)
+	var got struct {
+		Level  string
+		Msg    string
+		User   string
+		Source struct {
+			File string
+			Line int
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %q", err, out.String())
+	}
+	if got.Level != "WARN" || got.Msg != "retry 3" || got.User != "alice" {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if !strings.HasSuffix(got.Source.File, "slog_test.go") || got.Source.Line != line+1 {
+		t.Errorf("source = %s:%d, want slog_test.go:%d", got.Source.File, got.Source.Line, line+1)
+	}
+}
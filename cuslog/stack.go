@@ -0,0 +1,154 @@
+package cuslog
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// maxStackDepth 最多记录的调用栈层数
+const maxStackDepth = 64
+
+// cuslogPackage 本包的包路径，获取调用栈时跳过本包的帧
+var cuslogPackage = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(entry).Pointer()).Name()
+	return name[:strings.LastIndex(name, ".")]
+}()
+
+// internalPackage 日志转发经过的包，不属于调用方的调用栈
+func internalPackage(function string) bool {
+	slash := strings.LastIndex(function, "/")
+	dot := strings.IndexByte(function[slash+1:], '.')
+	if dot < 0 {
+		return false
+	}
+	switch function[:slash+1+dot] {
+	case cuslogPackage, "log", "log/slog":
+		return true
+	}
+	return false
+}
+
+// captureStack 记录调用栈，日志中的error自带调用栈时优先使用
+func (e *Entry) captureStack(skip int) {
+	pcs := e.errorStack()
+	if pcs == nil {
+		pcs = make([]uintptr, maxStackDepth)
+		pcs = pcs[:runtime.Callers(skip, pcs)]
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "runtime.goexit" && !internalPackage(frame.Function) {
+			e.Stack = append(e.Stack, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+}
+
+// errorStack 查找ErrorKey字段和参数中的error，返回错误链中最内层的调用栈
+func (e *Entry) errorStack() []uintptr {
+	if err, ok := e.Map[ErrorKey].(error); ok {
+		if pcs := errorChainStack(err); pcs != nil {
+			return pcs
+		}
+	}
+	for _, arg := range e.Args {
+		if err, ok := arg.(error); ok {
+			if pcs := errorChainStack(err); pcs != nil {
+				return pcs
+			}
+		}
+	}
+	return nil
+}
+
+func errorChainStack(err error) []uintptr {
+	var pcs []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		if stack := stackTraceOf(err); stack != nil {
+			pcs = stack
+		}
+		//兼容pkg/errors的Cause
+		if c, ok := err.(interface{ Cause() error }); ok && errors.Unwrap(err) == nil {
+			if cause := c.Cause(); cause != err {
+				if stack := errorChainStack(cause); stack != nil {
+					pcs = stack
+				}
+			}
+			break
+		}
+	}
+	return pcs
+}
+
+// stackTraceOf 支持pkg/errors风格的StackTrace()方法，返回值为元素是uintptr的切片，
+// 不直接依赖pkg/errors
+func stackTraceOf(err error) []uintptr {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+	out := method.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	stack := method.Call(nil)[0]
+	if stack.Len() == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, stack.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(stack.Index(i).Uint())
+	}
+	return pcs
+}
+
+// appendStack 每帧两行，函数名缩进一次，文件和行号缩进两次，与panic输出一致
+func appendStack(buf []byte, stack []Frame) []byte {
+	for _, f := range stack {
+		buf = append(buf, '\t')
+		buf = append(buf, f.Function...)
+		buf = append(buf, "\n\t\t"...)
+		buf = append(buf, f.File...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(f.Line), 10)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+// appendJSONStack stackFrames为true时输出数组，否则输出与text格式相同的字符串
+func appendJSONStack(buf []byte, stack []Frame, stackFrames bool) []byte {
+	if !stackFrames {
+		start := len(buf)
+		buf = appendStack(buf, stack)
+		s := string(buf[start:])
+		return appendJSONString(buf[:start], s)
+	}
+	buf = append(buf, '[')
+	for i, f := range stack {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"func":`...)
+		buf = appendJSONString(buf, f.Function)
+		buf = append(buf, `,"file":`...)
+		buf = appendJSONString(buf, f.File)
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendInt(buf, int64(f.Line), 10)
+		buf = append(buf, '}')
+	}
+	return append(buf, ']')
+}
@@ -0,0 +1,41 @@
+package cuslog
+
+import (
+	"log"
+)
+
+// stdLogCallerSkip 标准库log.Printf等函数到Write之间的调用层数：log.Printf->log.(*Logger).output->Write
+const stdLogCallerSkip = 2
+
+type levelWriter struct {
+	logger *logger
+	level  Level
+}
+
+// newLevelWriter 调用位置跳过标准库log内部的调用，指向log.Printf等函数的调用方
+func newLevelWriter(l *logger, level Level) *levelWriter {
+	return &levelWriter{logger: l.AddCallerSkip(stdLogCallerSkip), level: level}
+}
+
+func (w *levelWriter) Write(data []byte) (int, error) {
+	w.logger.writeLines(w.level, data, 1)
+	return len(data), nil
+}
+
+// NewStdLog 返回以level级别写入l的标准库logger
+func NewStdLog(l *logger, level Level) *log.Logger {
+	return log.New(newLevelWriter(l, level), "", 0)
+}
+
+// RedirectStdLog 将标准库log的输出以level级别重定向到l，并去掉log自带的前缀和时间等flag，返回恢复原设置的函数
+func RedirectStdLog(l *logger, level Level) func() {
+	flags, prefix, output := log.Flags(), log.Prefix(), log.Writer()
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(newLevelWriter(l, level))
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(output)
+	}
+}
@@ -0,0 +1,106 @@
+package cuslog
+
+import (
+	"io"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// argsHook 保留entry的参数，用于检查写入的数据是否被复制
+type argsHook struct {
+	mu   sync.Mutex
+	args []interface{}
+}
+
+func (h *argsHook) Levels() []Level {
+	return AllLevels()
+}
+
+func (h *argsHook) Fire(e *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.args = append(h.args, e.Args...)
+	return nil
+}
+
+func TestLoggerWriteLines(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true), WithStdLevel(WarnLevel))
+	p := []byte("first\r\n\nsecond\nthird\n\n")
+	n, err := l.Write(p)
+	if err != nil || n != len(p) {
+		t.Errorf("Write = %d, %v, want %d, nil", n, err, len(p))
+	}
+	lines := buf.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("got %q, want one entry per non-empty line", buf.String())
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if !strings.HasSuffix(lines[i], want) || !strings.Contains(lines[i], "WARN") {
+			t.Errorf("line %d = %q, want WARN ... %s", i, lines[i], want)
+		}
+	}
+	if n, err := l.Write(nil); n != 0 || err != nil || len(buf.Lines()) != 3 {
+		t.Errorf("empty write = %d, %v", n, err)
+	}
+}
+
+// io.Copy要求Write返回len(p)，短写会返回io.ErrShortWrite
+func TestLoggerWriteIOCopy(t *testing.T) {
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithDisableCaller(true))
+	if _, err := io.Copy(l, strings.NewReader("a\nb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(buf.Lines()); n != 2 {
+		t.Errorf("got %d lines, want 2", n)
+	}
+}
+
+func TestLoggerWriteCopiesData(t *testing.T) {
+	hook := &argsHook{}
+	l := New(WithOutput(io.Discard), WithHooks(hook))
+	p := []byte("original\n")
+	l.Write(p)
+	copy(p, "modified")
+	if got := hook.args[0]; got != "original" {
+		t.Errorf("entry args changed with the caller's buffer: %v", got)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	log.SetPrefix("legacy: ")
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	defer func() {
+		log.SetPrefix("")
+		log.SetFlags(log.LstdFlags)
+	}()
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf))
+	restore := RedirectStdLog(l, InfoLevel)
+	_, _, line, _ := runtime.Caller(0)
+	log.Printf("from %s", "stdlib")
+	restore()
+	got := buf.String()
+	if !strings.Contains(got, "INFO") || !strings.HasSuffix(got, "from stdlib\n") || strings.Contains(got, "legacy") {
+		t.Errorf("prefix and flags should be stripped: %q", got)
+	}
+	if want := "stdlog_test.go:" + strconv.Itoa(line+1); !strings.Contains(got, want) {
+		t.Errorf("caller should point at log.Printf, want %s in %q", want, got)
+	}
+	if log.Prefix() != "legacy: " || log.Flags() != log.LstdFlags|log.Lshortfile {
+		t.Error("restore should bring back the original prefix and flags")
+	}
+
+	buf = &lockedBuffer{}
+	std := NewStdLog(New(WithOutput(buf)), ErrorLevel)
+	_, _, line, _ = runtime.Caller(0)
+	std.Println("via NewStdLog")
+	if want := "stdlog_test.go:" + strconv.Itoa(line+1); !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), "ERROR") {
+		t.Errorf("want ERROR at %s, got %q", want, buf.String())
+	}
+}
@@ -0,0 +1,342 @@
+package cuslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyslogFacility syslog的facility
+type SyslogFacility uint8
+
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFtp
+	FacilityLocal0 SyslogFacility = iota + 4
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// syslog severity
+const (
+	severityEmerg = iota
+	severityAlert
+	severityCrit
+	severityErr
+	severityWarning
+	severityNotice
+	severityInfo
+	severityDebug
+)
+
+// DefaultStructuredDataID RFC 5424 STRUCTURED-DATA的默认SD-ID，32473为文档保留的企业编号
+const DefaultStructuredDataID = "fields@32473"
+
+func syslogSeverity(level Level) int {
+	if info := level.info(); info != nil {
+		return info.syslog
+	}
+	return severityNotice
+}
+
+// SyslogFormatter 输出RFC 5424格式，RFC3164为true时输出RFC 3164格式
+//
+// RFC 5424中Entry.Map输出到STRUCTURED-DATA，RFC 3164没有STRUCTURED-DATA，字段以key=value追加在消息后
+type SyslogFormatter struct {
+	RFC3164 bool
+	// Facility 为FacilityKern时使用FacilityUser，kern只保留给内核使用
+	Facility SyslogFacility
+	// Hostname 默认os.Hostname()
+	Hostname string
+	// AppName 默认为程序名
+	AppName string
+	// ProcID 默认为进程号
+	ProcID string
+	MsgID  string
+	// StructuredDataID 默认DefaultStructuredDataID
+	StructuredDataID string
+
+	once sync.Once
+}
+
+func (s *SyslogFormatter) init() {
+	s.once.Do(func() {
+		if s.Hostname == "" {
+			s.Hostname, _ = os.Hostname()
+		}
+		if s.AppName == "" {
+			s.AppName = filepath.Base(os.Args[0])
+		}
+		if s.ProcID == "" {
+			s.ProcID = strconv.Itoa(os.Getpid())
+		}
+		if s.Facility == FacilityKern {
+			s.Facility = FacilityUser
+		}
+		if s.StructuredDataID == "" {
+			s.StructuredDataID = DefaultStructuredDataID
+		}
+	})
+}
+
+func (s *SyslogFormatter) Format(buf []byte, e *Entry) ([]byte, error) {
+	s.init()
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(s.Facility)*8+int64(syslogSeverity(e.Level)), 10)
+	buf = append(buf, '>')
+	if s.RFC3164 {
+		return s.format3164(buf, e), nil
+	}
+	return s.format5424(buf, e), nil
+}
+
+// format5424 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID key="value"] MSG
+func (s *SyslogFormatter) format5424(buf []byte, e *Entry) []byte {
+	buf = append(buf, "1 "...)
+	buf = e.Time.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.Hostname, 255)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.AppName, 48)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.ProcID, 128)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.MsgID, 32)
+	buf = append(buf, ' ')
+	if keys := e.Keys(); len(keys) > 0 {
+		buf = append(buf, '[')
+		buf = appendSDName(buf, s.StructuredDataID)
+		for _, k := range keys {
+			buf = append(buf, ' ')
+			buf = appendSDName(buf, k)
+			buf = append(buf, `="`...)
+			start := len(buf)
+			buf = appendValue(buf, e.Map[k])
+			buf = escapeSDValue(buf, start)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, ']')
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, ' ')
+	buf = e.AppendMessage(buf)
+	return append(buf, '\n')
+}
+
+// format3164 <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+func (s *SyslogFormatter) format3164(buf []byte, e *Entry) []byte {
+	buf = e.Time.AppendFormat(buf, time.Stamp)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.Hostname, 255)
+	buf = append(buf, ' ')
+	buf = appendSyslogHeaderField(buf, s.AppName, 32)
+	buf = append(buf, '[')
+	buf = append(buf, s.ProcID...)
+	buf = append(buf, "]: "...)
+	buf = e.AppendMessage(buf)
+	for _, k := range e.Keys() {
+		buf = append(buf, ' ')
+		buf = appendLogfmtPair(buf, k, e.Map[k])
+	}
+	return append(buf, '\n')
+}
+
+// appendSyslogHeaderField 头部字段只允许可打印ASCII字符，为空时输出-
+func appendSyslogHeaderField(buf []byte, s string, max int) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+	for i := 0; i < len(s) && i < max; i++ {
+		c := s[i]
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// appendSDName SD-NAME最长32个可打印ASCII字符，不能包含= ] " 和空格
+func appendSDName(buf []byte, s string) []byte {
+	if s == "" {
+		return append(buf, '_')
+	}
+	for i := 0; i < len(s) && i < 32; i++ {
+		c := s[i]
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// escapeSDValue PARAM-VALUE中的" \ ]需要转义
+func escapeSDValue(buf []byte, start int) []byte {
+	n := 0
+	for _, c := range buf[start:] {
+		if c == '"' || c == '\\' || c == ']' {
+			n++
+		}
+	}
+	if n == 0 {
+		return buf
+	}
+	end := len(buf)
+	for i := 0; i < n; i++ {
+		buf = append(buf, 0)
+	}
+	//从后往前移动，插入转义符
+	j := len(buf) - 1
+	for i := end - 1; i >= start; i-- {
+		c := buf[i]
+		buf[j] = c
+		j--
+		if c == '"' || c == '\\' || c == ']' {
+			buf[j] = '\\'
+			j--
+		}
+	}
+	return buf
+}
+
+type syslogOptions struct {
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+type SyslogOption func(options2 *syslogOptions)
+
+// WithSyslogTLSConfig network为tls时使用的配置
+func WithSyslogTLSConfig(config *tls.Config) SyslogOption {
+	return SyslogOption(func(options2 *syslogOptions) {
+		options2.tlsConfig = config
+	})
+}
+
+func WithSyslogDialTimeout(timeout time.Duration) SyslogOption {
+	return SyslogOption(func(options2 *syslogOptions) {
+		options2.dialTimeout = timeout
+	})
+}
+
+// WithSyslogBackoff 重连失败后等待min再重试，每次失败翻倍，最长max
+func WithSyslogBackoff(min, max time.Duration) SyslogOption {
+	return SyslogOption(func(options2 *syslogOptions) {
+		options2.minBackoff, options2.maxBackoff = min, max
+	})
+}
+
+// SyslogWriter 将格式化后的syslog消息发送到syslog服务，可作为WithOutput的参数
+//
+// network支持udp、tcp、tls、unix和unixgram，tcp和tls使用RFC 6587的octet-counting分帧；
+// network和addr都为空时连接本地的/dev/log；第一次写入时才连接，连接失败时按退避时间重试
+type SyslogWriter struct {
+	network string
+	addr    string
+	opt     syslogOptions
+	conn    *netConn
+	//只在conn的锁内读写
+	stream bool
+}
+
+func NewSyslogWriter(network, addr string, opts ...SyslogOption) (*SyslogWriter, error) {
+	o := syslogOptions{dialTimeout: 5 * time.Second, minBackoff: 100 * time.Millisecond, maxBackoff: 30 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch network {
+	case "", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("cuslog: unsupported syslog network %q", network)
+	}
+	w := &SyslogWriter{network: network, addr: addr, opt: o}
+	w.conn = newNetConn(w.dial, o.minBackoff, o.maxBackoff)
+	return w, nil
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	//分帧由传输层负责，去掉formatter追加的换行
+	msg := p
+	for len(msg) > 0 && (msg[len(msg)-1] == '\n' || msg[len(msg)-1] == '\r') {
+		msg = msg[:len(msg)-1]
+	}
+	err := w.conn.write(func(conn net.Conn) error {
+		if !w.stream {
+			_, err := conn.Write(msg)
+			return err
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(w.opt.dialTimeout))
+		frame := make([]byte, 0, len(msg)+8)
+		frame = strconv.AppendInt(frame, int64(len(msg)), 10)
+		frame = append(frame, ' ')
+		frame = append(frame, msg...)
+		_, err := conn.Write(frame)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *SyslogWriter) Close() error {
+	return w.conn.close()
+}
+
+func (w *SyslogWriter) dial() (net.Conn, error) {
+	switch w.network {
+	case "":
+		conn, stream, err := dialLocalSyslog(w.addr, w.opt.dialTimeout)
+		w.stream = stream
+		return conn, err
+	case "tls":
+		w.stream = true
+		dialer := &net.Dialer{Timeout: w.opt.dialTimeout}
+		return tls.DialWithDialer(dialer, "tcp", w.addr, w.opt.tlsConfig)
+	}
+	w.stream = w.network == "tcp" || w.network == "tcp4" || w.network == "tcp6" || w.network == "unix"
+	return net.DialTimeout(w.network, w.addr, w.opt.dialTimeout)
+}
+
+// dialLocalSyslog 依次尝试常见的本地syslog socket，优先使用数据报
+func dialLocalSyslog(addr string, timeout time.Duration) (net.Conn, bool, error) {
+	paths := []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+	if addr != "" {
+		paths = []string{addr}
+	}
+	var lastErr error
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, path, timeout)
+			if err == nil {
+				return conn, network == "unix", nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, false, lastErr
+}
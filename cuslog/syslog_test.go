@@ -0,0 +1,195 @@
+package cuslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSyslogLogger(t *testing.T, network, addr string, f *SyslogFormatter) (*logger, *SyslogWriter) {
+	t.Helper()
+	w, err := NewSyslogWriter(network, addr, WithSyslogBackoff(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return New(WithOutput(w), WithFormatter(f), WithDisableCaller(true)), w
+}
+
+// readOctetFrame 读取一条RFC 6587 octet-counting分帧的消息
+func readOctetFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+	if err != nil {
+		t.Fatalf("invalid frame length %q", size)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	return string(msg)
+}
+
+func acceptOne(t *testing.T, ln net.Listener) *bufio.Reader {
+	t.Helper()
+	ln.(interface{ SetDeadline(time.Time) error }).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return bufio.NewReader(conn)
+}
+
+func TestSyslogFormatter5424(t *testing.T) {
+	f := &SyslogFormatter{Hostname: "host", AppName: "app", ProcID: "42", Facility: FacilityLocal0}
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithFormatter(f), WithDisableCaller(true))
+	l.WithField("q", `a"b]`).Warn("disk low")
+	line := buf.String()
+	//local0(16)*8 + warning(4)
+	if !strings.HasPrefix(line, "<132>1 ") {
+		t.Errorf("unexpected header: %q", line)
+	}
+	if !strings.Contains(line, ` host app 42 - [fields@32473 q="a\"b\]"] disk low`) {
+		t.Errorf("unexpected message: %q", line)
+	}
+}
+
+func TestSyslogFormatter3164(t *testing.T) {
+	f := &SyslogFormatter{RFC3164: true, Hostname: "host", AppName: "app", ProcID: "42"}
+	buf := &lockedBuffer{}
+	l := New(WithOutput(buf), WithFormatter(f), WithDisableCaller(true))
+	l.WithField("k", "v").Error("failed")
+	line := buf.String()
+	//kern被替换为user(1)*8 + err(3)
+	if !strings.HasPrefix(line, "<11>") || !strings.HasSuffix(line, " host app[42]: failed k=v\n") {
+		t.Errorf("unexpected message: %q", line)
+	}
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	l, _ := newSyslogLogger(t, "udp", pc.LocalAddr().String(), &SyslogFormatter{AppName: "app"})
+	l.Info("over udp")
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<14>1 ") || !strings.HasSuffix(msg, " over udp") {
+		t.Errorf("unexpected datagram: %q", msg)
+	}
+}
+
+func TestSyslogWriterTCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l, _ := newSyslogLogger(t, "tcp", ln.Addr().String(), &SyslogFormatter{AppName: "app"})
+	l.Info("first")
+	l.Info("second line\nwith newline")
+
+	r := acceptOne(t, ln)
+	if msg := readOctetFrame(t, r); !strings.HasSuffix(msg, " first") {
+		t.Errorf("unexpected frame: %q", msg)
+	}
+	if msg := readOctetFrame(t, r); !strings.HasSuffix(msg, " second line\nwith newline") {
+		t.Errorf("unexpected frame: %q", msg)
+	}
+}
+
+func TestSyslogWriterUnix(t *testing.T) {
+	dir := t.TempDir()
+	stream := filepath.Join(dir, "stream.sock")
+	ln, err := net.Listen("unix", stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l, _ := newSyslogLogger(t, "unix", stream, &SyslogFormatter{AppName: "app"})
+	l.Info("over unix")
+	if msg := readOctetFrame(t, acceptOne(t, ln)); !strings.HasSuffix(msg, " over unix") {
+		t.Errorf("unexpected frame: %q", msg)
+	}
+
+	//本地syslog socket，优先使用数据报
+	gram := filepath.Join(dir, "log.sock")
+	pc, err := net.ListenPacket("unixgram", gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	local, _ := newSyslogLogger(t, "", gram, &SyslogFormatter{AppName: "app"})
+	local.Info("over /dev/log")
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasSuffix(msg, " over /dev/log") {
+		t.Errorf("unexpected datagram: %q", msg)
+	}
+}
+
+func TestSyslogWriterReconnect(t *testing.T) {
+	//先占用再释放一个端口，启动时服务不可用
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w, err := NewSyslogWriter("tcp", addr, WithSyslogBackoff(50*time.Millisecond, time.Second))
+	if err != nil {
+		t.Fatalf("writer should be created while the server is down: %v", err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("<14>1 lost\n")); err == nil {
+		t.Fatal("write should fail while the server is down")
+	}
+	if _, err := w.Write([]byte("<14>1 lost\n")); !errors.Is(err, ErrConnBackoff) {
+		t.Fatalf("got %v, want ErrConnBackoff", err)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("port reused: %v", err)
+	}
+	defer ln.Close()
+	time.Sleep(60 * time.Millisecond)
+	if _, err := w.Write([]byte("<14>1 delivered\n")); err != nil {
+		t.Fatalf("write after server started: %v", err)
+	}
+	if msg := readOctetFrame(t, acceptOne(t, ln)); msg != "<14>1 delivered" {
+		t.Errorf("unexpected frame: %q", msg)
+	}
+}
+
+func TestSyslogWriterUnsupportedNetwork(t *testing.T) {
+	if _, err := NewSyslogWriter("http", "127.0.0.1:514"); err == nil {
+		t.Error("unsupported network should be rejected")
+	}
+}